@@ -0,0 +1,150 @@
+package ImageTools
+
+import (
+	"sync"
+)
+
+/*
+ * Thresholds an image against the mean of the size x size window around each pixel, minus a constant c
+ * White pixel if it's at or above the local threshold, otherwise black
+ * The local means come from an integral image, so the time taken doesn't depend on the window size
+ */
+func AdaptiveMeanThreshold(image [][]float32, size int, c float32) [][]float32 {
+	return localThreshold(image, size, func(mean float64, std float64) float64 {
+		return mean - float64(c)
+	})
+}
+
+/*
+ * Thresholds an image against a Gaussian weighted mean of the pixels around it (with standard deviation sigma), minus a constant c
+ * White pixel if it's at or above the local threshold, otherwise black
+ */
+func AdaptiveGaussianThreshold(image [][]float32, sigma float32, c float32) [][]float32 {
+	thresholds, _ := SubtractScalar(GaussianBlur(image, sigma), c, false)
+	return aboveThreshold(image, thresholds)
+}
+
+/*
+ * Thresholds an image with Niblack's method, where the threshold is mean + k * std of the size x size window around each pixel
+ * k is usually about -0.2 for dark text on a light background
+ */
+func NiblackThreshold(image [][]float32, size int, k float32) [][]float32 {
+	return localThreshold(image, size, func(mean float64, std float64) float64 {
+		return mean + float64(k) * std
+	})
+}
+
+/*
+ * Thresholds an image with Sauvola's method (https://doi.org/10.1016/S0031-3203(99)00055-2), where the threshold is mean * (1 + k * (std / r - 1)) over the size x size window around each pixel
+ * r is the dynamic range of the standard deviation, which is 0.5 for a normalised (0-1) image. k is usually between 0.2 and 0.5
+ * Unlike Niblack's method, flat background areas aren't turned into noise
+ */
+func SauvolaThreshold(image [][]float32, size int, k float32, r float32) [][]float32 {
+	return localThreshold(image, size, func(mean float64, std float64) float64 {
+		return mean * (1 + float64(k) * (std / float64(r) - 1))
+	})
+}
+
+/*
+ * Thresholds an image with Wolf and Jolion's method (https://doi.org/10.1007/s10032-004-0124-5), an improvement on Sauvola's method for low contrast images
+ * The threshold is (1 - k) * mean + k * min + k * (std / maxStd) * (mean - min), where min is the darkest pixel in the image and maxStd is the biggest local std
+ * k is usually 0.5
+ */
+func WolfThreshold(image [][]float32, size int, k float32) [][]float32 {
+
+	imageWidth, imageHeight := Dimensions(image)
+	integral := NewSquaredIntegralImage(image)
+
+	// Calculate the local means and stds
+	means := windowFilter(imageWidth, imageHeight, size, func(x int, y int) float64 {
+		return integral.Mean(x, y, size, size)
+	})
+	stds := windowFilter(imageWidth, imageHeight, size, func(x int, y int) float64 {
+		_, std := integral.MeanStd(x, y, size, size)
+		return std
+	})
+
+	// Find the global statistics
+	min, _ := MinMax(image)
+	_, maxStd := MinMax(stds)
+	if maxStd == 0 {
+		maxStd = 1
+	}
+
+	// Calculate the thresholds
+	thresholds := make([][]float32, imageWidth)
+	for i := range thresholds {
+		thresholds[i] = make([]float32, imageHeight)
+		for j := range thresholds[i] {
+			mean, std := means[i][j], stds[i][j]
+			thresholds[i][j] = (1 - k) * mean + k * min + k * (std / maxStd) * (mean - min)
+		}
+	}
+
+	return aboveThreshold(image, thresholds)
+}
+
+/*
+ * Thresholds an image with Bradley and Roth's method (https://doi.org/10.1080/2151237X.2007.10129236), where a pixel is black if it's more than t (as a fraction) darker than the mean of the size x size window around it
+ * t is usually about 0.15
+ */
+func BradleyThreshold(image [][]float32, size int, t float32) [][]float32 {
+	return localThreshold(image, size, func(mean float64, std float64) float64 {
+		return mean * (1 - float64(t))
+	})
+}
+
+/*
+ * Thresholds an image against a function of the mean and std of the size x size window around each pixel
+ * The window statistics come from a squared integral image, so the time taken doesn't depend on the window size
+ */
+func localThreshold(image [][]float32, size int, threshold func(mean float64, std float64) float64) [][]float32 {
+	imageWidth, imageHeight := Dimensions(image)
+	integral := NewSquaredIntegralImage(image)
+	thresholds := windowFilter(imageWidth, imageHeight, size, func(x int, y int) float64 {
+		return threshold(integral.MeanStd(x, y, size, size))
+	})
+	return aboveThreshold(image, thresholds)
+}
+
+/*
+ * Thresholds each pixel of an image against the same pixel of a threshold image
+ * White pixel if it's at or above the threshold, otherwise black
+ */
+func aboveThreshold(image [][]float32, thresholds [][]float32) [][]float32 {
+
+	imageWidth, imageHeight := Dimensions(image)
+
+	// Create output image
+	outputImage := make([][]float32, imageWidth)
+	for j := range outputImage {
+		outputImage[j] = make([]float32, imageHeight)
+	}
+
+	// Create wait group
+	var waitGroup sync.WaitGroup
+	waitGroup.Add(imageHeight)
+
+	// Iterate over columns
+	for j := 0; j < imageHeight; j++ {
+
+		// Process each row on its own goroutine
+		go func(j int) {
+			defer waitGroup.Done()
+
+			// Iterate over row
+			for i := 0; i < imageWidth; i++ {
+
+				// Apply threshold to each pixel
+				if image[i][j] >= thresholds[i][j] {
+					outputImage[i][j] = 1
+				}
+			}
+		} (j)
+	}
+
+	// Wait for all goroutines to finish
+	waitGroup.Wait()
+
+	return outputImage
+}
\ No newline at end of file
@@ -0,0 +1,482 @@
+package ImageTools
+
+import (
+	"errors"
+	"math"
+	"sort"
+)
+
+/*
+ * A global threshold selection method, which picks a threshold from a histogram
+ * Pixels at or above the threshold are in the foreground
+ */
+type ThresholdMethod func(histogram *Histogram) (float32, error)
+
+/*
+ * The threshold selection methods by name, so a method can be chosen at runtime
+ */
+var thresholdMethods = map[string]ThresholdMethod{
+	"otsu":          OtsuThreshold,
+	"triangle":      TriangleThreshold,
+	"li":            LiThreshold,
+	"yen":           YenThreshold,
+	"kapur":         KapurThreshold,
+	"isodata":       IsoDataThreshold,
+	"minimum-error": MinimumErrorThreshold,
+}
+
+/*
+ * Looks up a threshold selection method by name (see ThresholdMethodNames)
+ */
+func LookupThresholdMethod(name string) (ThresholdMethod, error) {
+	method, ok := thresholdMethods[name]
+	if !ok {
+		return nil, errors.New("Unknown threshold method")
+	}
+	return method, nil
+}
+
+/*
+ * Returns the names of the threshold selection methods that LookupThresholdMethod knows about, in alphabetical order
+ */
+func ThresholdMethodNames() []string {
+	names := make([]string, 0, len(thresholdMethods))
+	for name := range thresholdMethods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+/*
+ * Binarises an image with a global threshold selection method, using a histogram of bins bins covering the image's range
+ * Returns the threshold and the binarised image, which is the same as SingleThreshold(image, threshold)
+ */
+func AutomaticThreshold(image [][]float32, method ThresholdMethod, bins int) (float32, [][]float32, error) {
+	min, max := MinMax(image)
+	if max <= min {
+		return 0, nil, errors.New("Can't threshold a flat image")
+	}
+	histogram, err := NewHistogram(image, bins, min, max, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	threshold, err := method(histogram)
+	if err != nil {
+		return 0, nil, err
+	}
+	return threshold, SingleThreshold(image, threshold), nil
+}
+
+/*
+ * Binarises an image with Otsu's method (https://doi.org/10.1109/TSMC.1979.4310076)
+ * The threshold is chosen to maximise the variance between the two classes, using a histogram of bins bins covering the image's range
+ * Returns the threshold and the binarised image, which is the same as SingleThreshold(image, threshold)
+ */
+func Otsu(image [][]float32, bins int) (float32, [][]float32, error) {
+	thresholds, _, err := MultiOtsu(image, 2, bins)
+	if err != nil {
+		return 0, nil, err
+	}
+	return thresholds[0], SingleThreshold(image, thresholds[0]), nil
+}
+
+/*
+ * Splits an image into classes with multi-level Otsu's method (https://doi.org/10.1109/TSMC.1979.4310076)
+ * The classes - 1 thresholds are chosen to maximise the variance between the classes, using a histogram of bins bins covering the image's range
+ * Returns the thresholds in increasing order, and a label image from MultiThreshold with values 0 to classes - 1
+ * With 3 classes, the middle class is the pixels labelled 1, which are at or above thresholds[0] and below thresholds[1]
+ * DualThreshold(image, thresholds[0], thresholds[1]) is nearly the same, but it leaves out pixels exactly on thresholds[0], which is common in quantised images
+ */
+func MultiOtsu(image [][]float32, classes int, bins int) ([]float32, [][]float32, error) {
+	min, max := MinMax(image)
+	if max <= min {
+		return nil, nil, errors.New("Can't threshold a flat image")
+	}
+	histogram, err := NewHistogram(image, bins, min, max, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	thresholds, err := MultiOtsuThresholds(histogram, classes)
+	if err != nil {
+		return nil, nil, err
+	}
+	return thresholds, MultiThreshold(image, thresholds), nil
+}
+
+/*
+ * Calculates Otsu's threshold from a histogram, so it can be used with a mask or a histogram that has been shared with something else
+ * Pixels at or above the threshold are in the upper class
+ */
+func OtsuThreshold(histogram *Histogram) (float32, error) {
+	thresholds, err := MultiOtsuThresholds(histogram, 2)
+	if err != nil {
+		return 0, err
+	}
+	return thresholds[0], nil
+}
+
+/*
+ * Calculates the classes - 1 multi-level Otsu thresholds from a histogram, in increasing order
+ * Pixels at or above a threshold are in the class above it
+ * The search is exact, using dynamic programming over the bins, so it takes time proportional to classes * bins^2
+ */
+func MultiOtsuThresholds(histogram *Histogram, classes int) ([]float32, error) {
+
+	// Check the parameters
+	bins := histogram.Bins()
+	if classes < 2 {
+		return nil, errors.New("There must be at least 2 classes")
+	}
+	if classes > bins {
+		return nil, errors.New("There must be at least as many bins as classes")
+	}
+	if histogram.Total() <= 0 {
+		return nil, errors.New("Histogram is empty")
+	}
+
+	// Prefix sums of the probabilities and first moments, so any class's weight and mean can be found in constant time
+	probabilities := histogram.Probabilities()
+	weights := make([]float64, bins + 1)
+	moments := make([]float64, bins + 1)
+	for b, probability := range probabilities {
+		weights[b + 1] = weights[b] + probability
+		moments[b + 1] = moments[b] + probability * float64(histogram.BinCentre(b))
+	}
+
+	// Maximising the between class variance is the same as maximising the sum of each class's moment^2 / weight
+	score := func(first int, last int) float64 {
+		weight := weights[last + 1] - weights[first]
+		if weight <= 0 {
+			return 0
+		}
+		moment := moments[last + 1] - moments[first]
+		return moment * moment / weight
+	}
+
+	// best[k][b] is the best score for splitting bins 0 to b into k + 1 classes, and start[k][b] is where the last of those classes starts
+	best := make([][]float64, classes)
+	start := make([][]int, classes)
+	for k := range best {
+		best[k] = make([]float64, bins)
+		start[k] = make([]int, bins)
+	}
+	for b := 0; b < bins; b++ {
+		best[0][b] = score(0, b)
+	}
+	for k := 1; k < classes; k++ {
+		for b := k; b < bins; b++ {
+			best[k][b] = -1
+			for first := k; first <= b; first++ {
+				candidate := best[k - 1][first - 1] + score(first, b)
+				if candidate > best[k][b] {
+					best[k][b], start[k][b] = candidate, first
+				}
+			}
+		}
+	}
+
+	// Trace back the class boundaries
+	thresholds := make([]float32, classes - 1)
+	last := bins - 1
+	for k := classes - 1; k > 0; k-- {
+		first := start[k][last]
+		thresholds[k - 1] = histogram.splitThreshold(first)
+		last = first - 1
+	}
+
+	return thresholds, nil
+}
+
+/*
+ * Chooses a threshold with the triangle method (https://doi.org/10.1177/25.7.70454), which works well for unimodal histograms with a long tail
+ * A line is drawn from the peak of the histogram to the far end of its longer tail, and the threshold is where the histogram is furthest below the line
+ */
+func TriangleThreshold(histogram *Histogram) (float32, error) {
+	if histogram.Total() <= 0 {
+		return 0, errors.New("Histogram is empty")
+	}
+
+	// Find the peak and the first and last non-empty bins
+	bins := histogram.Bins()
+	peak, first, last := 0, -1, 0
+	for b, count := range histogram.Counts {
+		if count > histogram.Counts[peak] {
+			peak = b
+		}
+		if count > 0 {
+			if first < 0 {
+				first = b
+			}
+			last = b
+		}
+	}
+
+	// Work along the longer tail, flipping the histogram if it is on the left
+	counts := histogram.Counts
+	flipped := peak - first > last - peak
+	if flipped {
+		counts = make([]float64, bins)
+		for b := range counts {
+			counts[b] = histogram.Counts[bins - 1 - b]
+		}
+		peak, last = bins - 1 - peak, bins - 1 - first
+	}
+
+	// Find the bin furthest below the line from (peak, peak count) to (last + 1, 0)
+	best, bestDistance := peak, -1.0
+	height, length := counts[peak], float64(last + 1 - peak)
+	for b := peak; b <= last; b++ {
+
+		// The distance is proportional to this, and the constant factor doesn't matter
+		distance := height * (1 - float64(b - peak) / length) - counts[b]
+		if distance > bestDistance {
+			best, bestDistance = b, distance
+		}
+	}
+
+	if flipped {
+		return histogram.splitThreshold(bins - 1 - best), nil
+	}
+	return histogram.splitThreshold(best + 1), nil
+}
+
+/*
+ * Chooses a threshold with Li's minimum cross entropy method (https://doi.org/10.1016/0031-3203(93)90115-D)
+ * The threshold minimises the cross entropy between the image and the image with each class replaced by its mean
+ * Pixel values are measured from the bottom of the histogram, since the cross entropy needs them to be positive
+ */
+func LiThreshold(histogram *Histogram) (float32, error) {
+	stats, err := newSplitStatistics(histogram)
+	if err != nil {
+		return 0, err
+	}
+	return stats.best(func(k int) (float64, bool) {
+		below, above := stats.below(k), stats.above(k)
+		if below.weight <= 0 || above.weight <= 0 {
+			return 0, false
+		}
+		return below.moment * math.Log(below.mean()) + above.moment * math.Log(above.mean()), true
+	}), nil
+}
+
+/*
+ * Chooses a threshold with Yen's maximum correlation method (https://doi.org/10.1109/83.366472)
+ */
+func YenThreshold(histogram *Histogram) (float32, error) {
+	stats, err := newSplitStatistics(histogram)
+	if err != nil {
+		return 0, err
+	}
+	return stats.best(func(k int) (float64, bool) {
+		below, above := stats.below(k), stats.above(k)
+		if below.weight <= 0 || above.weight <= 0 || below.squares <= 0 || above.squares <= 0 {
+			return 0, false
+		}
+		return -math.Log(below.squares * above.squares) + 2 * math.Log(below.weight * above.weight), true
+	}), nil
+}
+
+/*
+ * Chooses a threshold with Kapur, Sahoo and Wong's maximum entropy method (https://doi.org/10.1016/0734-189X(85)90125-2)
+ * The threshold maximises the sum of the entropies of the two classes
+ */
+func KapurThreshold(histogram *Histogram) (float32, error) {
+	stats, err := newSplitStatistics(histogram)
+	if err != nil {
+		return 0, err
+	}
+
+	// The entropy of a class is log(P) - sum(p log p) / P, where P is its weight
+	return stats.best(func(k int) (float64, bool) {
+		below, above := stats.below(k), stats.above(k)
+		if below.weight <= 0 || above.weight <= 0 {
+			return 0, false
+		}
+		return math.Log(below.weight) - below.entropy / below.weight + math.Log(above.weight) - above.entropy / above.weight, true
+	}), nil
+}
+
+/*
+ * Chooses a threshold with the IsoData (Ridler-Calvard) method (https://doi.org/10.1109/TSMC.1978.4310039)
+ * Starting from the mean, the threshold is repeatedly moved to halfway between the means of the two classes, until it stops changing
+ */
+func IsoDataThreshold(histogram *Histogram) (float32, error) {
+	stats, err := newSplitStatistics(histogram)
+	if err != nil {
+		return 0, err
+	}
+
+	// k is the first bin of the upper class
+	k := histogram.Bin(histogram.Mean())
+	for iteration := 0; iteration < histogram.Bins(); iteration++ {
+		below, above := stats.below(k), stats.above(k)
+		if below.weight <= 0 || above.weight <= 0 {
+			break
+		}
+
+		// Move to the bin edge closest to halfway between the class means
+		midpoint := (below.mean() + above.mean()) / 2 + stats.offset
+		next := int(math.Round(float64(midpoint - float64(histogram.Min)) / float64(histogram.BinWidth())))
+		if next < 1 {
+			next = 1
+		}
+		if next > histogram.Bins() - 1 {
+			next = histogram.Bins() - 1
+		}
+		if next == k {
+			break
+		}
+		k = next
+	}
+
+	return histogram.Min + float32(k) * histogram.BinWidth(), nil
+}
+
+/*
+ * Chooses a threshold with Kittler and Illingworth's minimum error method (https://doi.org/10.1016/0031-3203(86)90030-0)
+ * Each class is modelled as a Gaussian, and the threshold minimises the classification error
+ */
+func MinimumErrorThreshold(histogram *Histogram) (float32, error) {
+	stats, err := newSplitStatistics(histogram)
+	if err != nil {
+		return 0, err
+	}
+
+	// The criterion to minimise is P1 log(var1) + P2 log(var2) - 2 (P1 log(P1) + P2 log(P2)), using log(var) = 2 log(std)
+	return stats.best(func(k int) (float64, bool) {
+		below, above := stats.below(k), stats.above(k)
+		belowVariance, aboveVariance := below.variance(), above.variance()
+		if below.weight <= 0 || above.weight <= 0 || belowVariance <= 0 || aboveVariance <= 0 {
+			return 0, false
+		}
+		criterion := below.weight * math.Log(belowVariance) + above.weight * math.Log(aboveVariance) - 2 * (below.weight * math.Log(below.weight) + above.weight * math.Log(above.weight))
+		return -criterion, true
+	}), nil
+}
+
+/*
+ * Returns the threshold between bin first - 1 and bin first
+ * If there are empty bins above the split, moving it through them doesn't change which pixels are in each class, so it is put in the middle of the gap
+ */
+func (histogram *Histogram) splitThreshold(first int) float32 {
+	gapEnd := first
+	for gapEnd < histogram.Bins() - 1 && histogram.Counts[gapEnd] == 0 {
+		gapEnd++
+	}
+	return histogram.Min + float32(first + gapEnd) / 2 * histogram.BinWidth()
+}
+
+/*
+ * Prefix sums over a histogram, so the statistics of the classes either side of any split can be found in constant time
+ * Pixel values are measured from offset, half a bin below the bottom of the histogram, so they are all positive
+ */
+type splitStatistics struct {
+	histogram *Histogram
+	offset float64
+	weights, moments, squareMoments, squares, entropies []float64
+}
+
+/*
+ * The statistics of one class
+ */
+type classStatistics struct {
+	weight, moment, squareMoment, squares, entropy float64
+}
+
+/*
+ * Returns the mean of a class, measured from the offset
+ */
+func (class classStatistics) mean() float64 {
+	return class.moment / class.weight
+}
+
+/*
+ * Returns the (population) variance of a class
+ */
+func (class classStatistics) variance() float64 {
+	mean := class.mean()
+	return class.squareMoment / class.weight - mean * mean
+}
+
+/*
+ * Calculates the prefix sums over a histogram
+ */
+func newSplitStatistics(histogram *Histogram) (*splitStatistics, error) {
+	if histogram.Bins() < 2 {
+		return nil, errors.New("There must be at least 2 bins")
+	}
+	if histogram.Total() <= 0 {
+		return nil, errors.New("Histogram is empty")
+	}
+
+	bins := histogram.Bins()
+	stats := &splitStatistics{
+		histogram:     histogram,
+		offset:        float64(histogram.Min) - float64(histogram.BinWidth()) / 2,
+		weights:       make([]float64, bins + 1),
+		moments:       make([]float64, bins + 1),
+		squareMoments: make([]float64, bins + 1),
+		squares:       make([]float64, bins + 1),
+		entropies:     make([]float64, bins + 1),
+	}
+	for b, p := range histogram.Probabilities() {
+		value := float64(histogram.BinCentre(b)) - stats.offset
+		entropy := float64(0)
+		if p > 0 {
+			entropy = p * math.Log(p)
+		}
+		stats.weights[b + 1] = stats.weights[b] + p
+		stats.moments[b + 1] = stats.moments[b] + p * value
+		stats.squareMoments[b + 1] = stats.squareMoments[b] + p * value * value
+		stats.squares[b + 1] = stats.squares[b] + p * p
+		stats.entropies[b + 1] = stats.entropies[b] + entropy
+	}
+	return stats, nil
+}
+
+/*
+ * Returns the statistics of the bins from first up to (but not including) last
+ */
+func (stats *splitStatistics) class(first int, last int) classStatistics {
+	return classStatistics{
+		weight:       stats.weights[last] - stats.weights[first],
+		moment:       stats.moments[last] - stats.moments[first],
+		squareMoment: stats.squareMoments[last] - stats.squareMoments[first],
+		squares:      stats.squares[last] - stats.squares[first],
+		entropy:      stats.entropies[last] - stats.entropies[first],
+	}
+}
+
+/*
+ * Returns the statistics of the lower class, when bin k is the first bin of the upper class
+ */
+func (stats *splitStatistics) below(k int) classStatistics {
+	return stats.class(0, k)
+}
+
+/*
+ * Returns the statistics of the upper class, when bin k is the first bin of the upper class
+ */
+func (stats *splitStatistics) above(k int) classStatistics {
+	return stats.class(k, stats.histogram.Bins())
+}
+
+/*
+ * Tries every split and returns the threshold for the one with the highest score
+ * The score function returns false for splits that aren't valid, such as ones that leave a class empty
+ */
+func (stats *splitStatistics) best(score func(k int) (float64, bool)) float32 {
+	bestK, bestScore := -1, math.Inf(-1)
+	for k := 1; k < stats.histogram.Bins(); k++ {
+		if value, ok := score(k); ok && value > bestScore {
+			bestK, bestScore = k, value
+		}
+	}
+
+	// If no split was valid, put everything in the upper class
+	if bestK < 0 {
+		return stats.histogram.Min
+	}
+	return stats.histogram.splitThreshold(bestK)
+}
\ No newline at end of file
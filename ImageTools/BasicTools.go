@@ -170,6 +170,44 @@ func Normalise(image [][]float32) [][]float32 {
 	return image
 }
 
+/*
+ * Clamps all pixel values in the range min-max (inclusive)
+ * Unlike Normalise, pixels that are already in range are left alone
+ */
+func Clamp(image [][]float32, min float32, max float32) [][]float32 {
+
+	imageWidth, imageHeight := Dimensions(image)
+
+	// Create wait group
+	var waitGroup sync.WaitGroup
+	waitGroup.Add(imageHeight)
+
+	// Iterate over columns
+	for j := 0; j < imageHeight; j++ {
+
+		// Process each row on its own goroutine
+		go func(j int) {
+			defer waitGroup.Done()
+
+			// Iterate over row
+			for i := 0; i < imageWidth; i++ {
+
+				// Clamp each pixel
+				if image[i][j] < min {
+					image[i][j] = min
+				} else if image[i][j] > max {
+					image[i][j] = max
+				}
+			}
+		} (j)
+	}
+
+	// Wait for all goroutines to finish
+	waitGroup.Wait()
+
+	return image
+}
+
 /*
  * Inverts an image, while preserving dynamic range
  */
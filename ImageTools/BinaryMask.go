@@ -0,0 +1,419 @@
+package ImageTools
+
+import (
+	"errors"
+	"math/bits"
+	"sync"
+)
+
+/*
+ * A binary image packed into bits, 64 pixels to a word
+ * Each row is packed along x, so shifting a row along x is a few word shifts, and morphology works on 64 pixels at once
+ * Bits past the end of each row are always 0
+ */
+type BinaryMask struct {
+	width, height int
+	rows [][]uint64
+}
+
+/*
+ * Creates an empty binary mask of a given size
+ */
+func NewBinaryMask(width int, height int) *BinaryMask {
+	words := (width + 63) / 64
+	rows := make([][]uint64, height)
+	for j := range rows {
+		rows[j] = make([]uint64, words)
+	}
+	return &BinaryMask{width: width, height: height, rows: rows}
+}
+
+/*
+ * Creates a binary mask from an image, such as the output of SingleThreshold. Non-zero pixels are set
+ */
+func NewBinaryMaskFromImage(image [][]float32) *BinaryMask {
+
+	imageWidth, imageHeight := Dimensions(image)
+	mask := NewBinaryMask(imageWidth, imageHeight)
+
+	// Create wait group
+	var waitGroup sync.WaitGroup
+	waitGroup.Add(imageHeight)
+
+	// Iterate over columns
+	for j := 0; j < imageHeight; j++ {
+
+		// Process each row on its own goroutine
+		go func(j int) {
+			defer waitGroup.Done()
+
+			// Iterate over row
+			for i := 0; i < imageWidth; i++ {
+				if image[i][j] != 0 {
+					mask.rows[j][i / 64] |= 1 << uint(i % 64)
+				}
+			}
+		} (j)
+	}
+
+	// Wait for all goroutines to finish
+	waitGroup.Wait()
+
+	return mask
+}
+
+/*
+ * Converts a binary mask to an image, with set pixels at 1 and everything else at 0
+ */
+func (mask *BinaryMask) ToImage() [][]float32 {
+
+	// Create output image
+	outputImage := make([][]float32, mask.width)
+	for j := range outputImage {
+		outputImage[j] = make([]float32, mask.height)
+	}
+
+	// Create wait group
+	var waitGroup sync.WaitGroup
+	waitGroup.Add(mask.height)
+
+	// Iterate over columns
+	for j := 0; j < mask.height; j++ {
+
+		// Process each row on its own goroutine
+		go func(j int) {
+			defer waitGroup.Done()
+
+			// Iterate over row
+			for i := 0; i < mask.width; i++ {
+				if mask.rows[j][i / 64] & (1 << uint(i % 64)) != 0 {
+					outputImage[i][j] = 1
+				}
+			}
+		} (j)
+	}
+
+	// Wait for all goroutines to finish
+	waitGroup.Wait()
+
+	return outputImage
+}
+
+/*
+ * Returns the width and height of the mask
+ */
+func (mask *BinaryMask) Dimensions() (int, int) {
+	return mask.width, mask.height
+}
+
+/*
+ * Returns whether a pixel is set. Pixels off the edge of the mask are never set
+ */
+func (mask *BinaryMask) Get(x int, y int) bool {
+	if x < 0 || x >= mask.width || y < 0 || y >= mask.height {
+		return false
+	}
+	return mask.rows[y][x / 64] & (1 << uint(x % 64)) != 0
+}
+
+/*
+ * Sets or clears a pixel. Pixels off the edge of the mask are ignored
+ */
+func (mask *BinaryMask) Set(x int, y int, value bool) {
+	if x < 0 || x >= mask.width || y < 0 || y >= mask.height {
+		return
+	}
+	if value {
+		mask.rows[y][x / 64] |= 1 << uint(x % 64)
+	} else {
+		mask.rows[y][x / 64] &^= 1 << uint(x % 64)
+	}
+}
+
+/*
+ * Returns the number of set pixels
+ */
+func (mask *BinaryMask) Count() int {
+	count := 0
+	for _, row := range mask.rows {
+		for _, word := range row {
+			count += bits.OnesCount64(word)
+		}
+	}
+	return count
+}
+
+/*
+ * Returns a copy of the mask
+ */
+func (mask *BinaryMask) Copy() *BinaryMask {
+	copied := NewBinaryMask(mask.width, mask.height)
+	for j := range mask.rows {
+		copy(copied.rows[j], mask.rows[j])
+	}
+	return copied
+}
+
+/*
+ * Returns whether two masks are the same size and have the same pixels set
+ */
+func (mask *BinaryMask) Equal(other *BinaryMask) bool {
+	if mask.width != other.width || mask.height != other.height {
+		return false
+	}
+	for j := range mask.rows {
+		for w := range mask.rows[j] {
+			if mask.rows[j][w] != other.rows[j][w] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+/*
+ * Returns the complement of the mask
+ */
+func (mask *BinaryMask) Not() *BinaryMask {
+	output := NewBinaryMask(mask.width, mask.height)
+	for j := range mask.rows {
+		for w := range mask.rows[j] {
+			output.rows[j][w] = ^mask.rows[j][w]
+		}
+		output.clearPadding(output.rows[j])
+	}
+	return output
+}
+
+/*
+ * Returns the intersection of two masks, which must be the same size
+ */
+func (mask *BinaryMask) And(other *BinaryMask) (*BinaryMask, error) {
+	return mask.combine(other, func(a uint64, b uint64) uint64 { return a & b })
+}
+
+/*
+ * Returns the union of two masks, which must be the same size
+ */
+func (mask *BinaryMask) Or(other *BinaryMask) (*BinaryMask, error) {
+	return mask.combine(other, func(a uint64, b uint64) uint64 { return a | b })
+}
+
+/*
+ * Returns the pixels that are set in this mask but not in the other, which must be the same size
+ */
+func (mask *BinaryMask) AndNot(other *BinaryMask) (*BinaryMask, error) {
+	return mask.combine(other, func(a uint64, b uint64) uint64 { return a &^ b })
+}
+
+/*
+ * Combines two masks a word at a time
+ */
+func (mask *BinaryMask) combine(other *BinaryMask, operation func(a uint64, b uint64) uint64) (*BinaryMask, error) {
+	if mask.width != other.width || mask.height != other.height {
+		return nil, errors.New("Masks must be the same size")
+	}
+	output := NewBinaryMask(mask.width, mask.height)
+	for j := range mask.rows {
+		for w := range mask.rows[j] {
+			output.rows[j][w] = operation(mask.rows[j][w], other.rows[j][w])
+		}
+	}
+	return output, nil
+}
+
+/*
+ * Performs exact morphological erosion. A pixel stays set only if every point of the structuring element lands on a set pixel
+ * Points where element is non-zero are part of the structuring element, which is centred in the same way as a Convolution kernel
+ * Points that land off the edge of the mask are ignored, the same as GrayscaleErosion
+ */
+func (mask *BinaryMask) Erode(element [][]float32) (*BinaryMask, error) {
+	offsets, _, err := structuringOffsets(element, nil, false)
+	if err != nil {
+		return nil, err
+	}
+	return mask.shiftAndCombine(offsets, false), nil
+}
+
+/*
+ * Performs exact morphological dilation. A pixel is set if the reflected structuring element centred on it lands on any set pixel
+ * Points that land off the edge of the mask are ignored, the same as GrayscaleDilation
+ */
+func (mask *BinaryMask) Dilate(element [][]float32) (*BinaryMask, error) {
+	offsets, _, err := structuringOffsets(element, nil, true)
+	if err != nil {
+		return nil, err
+	}
+	return mask.shiftAndCombine(offsets, true), nil
+}
+
+/*
+ * Performs exact morphological opening (erosion followed by dilation)
+ */
+func (mask *BinaryMask) Open(element [][]float32) (*BinaryMask, error) {
+
+	// Erode
+	eroded, err := mask.Erode(element)
+	if err != nil {
+		return nil, err
+	}
+
+	// Dilate
+	return eroded.Dilate(element)
+}
+
+/*
+ * Performs exact morphological closing (dilation followed by erosion)
+ */
+func (mask *BinaryMask) Close(element [][]float32) (*BinaryMask, error) {
+
+	// Dilate
+	dilated, err := mask.Dilate(element)
+	if err != nil {
+		return nil, err
+	}
+
+	// Erode
+	return dilated.Erode(element)
+}
+
+/*
+ * Performs the hit-or-miss transform, which finds the pixels where a pattern matches
+ * A pixel is set if every point of hits lands on a set pixel and every point of misses lands on a clear pixel. Both are centred in the same way as a Convolution kernel, and shouldn't overlap
+ * misses can be nil or have no points, in which case nothing is checked against the clear pixels and this is the same as Erode(hits)
+ * Points that land off the edge of the mask are ignored
+ */
+func (mask *BinaryMask) HitOrMiss(hits [][]float32, misses [][]float32) (*BinaryMask, error) {
+
+	// Erode the mask by the hits
+	hitOffsets, _, err := structuringOffsets(hits, nil, false)
+	if err != nil {
+		return nil, err
+	}
+	hitMatches := mask.shiftAndCombine(hitOffsets, false)
+
+	// With no misses there's nothing else to check
+	empty := true
+	for i := range misses {
+		for j := range misses[i] {
+			if misses[i][j] != 0 {
+				empty = false
+			}
+		}
+	}
+	if empty {
+		return hitMatches, nil
+	}
+
+	// Erode the complement by the misses
+	missOffsets, _, err := structuringOffsets(misses, nil, false)
+	if err != nil {
+		return nil, err
+	}
+	missMatches := mask.Not().shiftAndCombine(missOffsets, false)
+
+	return hitMatches.And(missMatches)
+}
+
+/*
+ * ANDs (for erosion) or ORs (for dilation) together copies of the mask shifted by each offset
+ * Pixels shifted in from off the edge of the mask don't change the result
+ */
+func (mask *BinaryMask) shiftAndCombine(offsets []structuringOffset, dilate bool) *BinaryMask {
+
+	output := NewBinaryMask(mask.width, mask.height)
+	words := (mask.width + 63) / 64
+
+	// Create wait group
+	var waitGroup sync.WaitGroup
+	waitGroup.Add(mask.height)
+
+	// Process each row on its own goroutine
+	for j := 0; j < mask.height; j++ {
+		go func(j int) {
+			defer waitGroup.Done()
+			row := output.rows[j]
+			shifted := make([]uint64, words)
+
+			// Erosion starts with every pixel set and dilation with none
+			if !dilate {
+				for w := range row {
+					row[w] = ^uint64(0)
+				}
+			}
+
+			for _, offset := range offsets {
+
+				// Rows off the edge of the mask are ignored
+				y := j + offset.y
+				if y < 0 || y >= mask.height {
+					continue
+				}
+				mask.shiftRow(mask.rows[y], offset.x, !dilate, shifted)
+				for w := range row {
+					if dilate {
+						row[w] |= shifted[w]
+					} else {
+						row[w] &= shifted[w]
+					}
+				}
+			}
+			output.clearPadding(row)
+		} (j)
+	}
+
+	// Wait for all goroutines to finish
+	waitGroup.Wait()
+
+	return output
+}
+
+/*
+ * Shifts a row so that bit x of the output is bit x + dx of the input
+ * Bits shifted in from off the ends of the row are set to fill
+ */
+func (mask *BinaryMask) shiftRow(row []uint64, dx int, fill bool, output []uint64) {
+
+	words := len(row)
+	fillWord := uint64(0)
+	if fill {
+		fillWord = ^uint64(0)
+	}
+
+	// The bits past the end of the last word are 0 in the mask, so they have to be filled in too
+	padding := uint64(0)
+	if mask.width % 64 != 0 {
+		padding = ^uint64(0) << uint(mask.width % 64)
+	}
+	word := func(w int) uint64 {
+		if w < 0 || w >= words {
+			return fillWord
+		}
+		if w == words - 1 {
+			return row[w] | (padding & fillWord)
+		}
+		return row[w]
+	}
+
+	// Split the shift into whole words and bits, rounding down so the bit shift is positive
+	wordShift, bitShift := dx / 64, dx % 64
+	if bitShift < 0 {
+		wordShift, bitShift = wordShift - 1, bitShift + 64
+	}
+	for w := range output {
+		if bitShift == 0 {
+			output[w] = word(w + wordShift)
+		} else {
+			output[w] = word(w + wordShift) >> uint(bitShift) | word(w + wordShift + 1) << uint(64 - bitShift)
+		}
+	}
+}
+
+/*
+ * Clears the bits past the end of a row
+ */
+func (mask *BinaryMask) clearPadding(row []uint64) {
+	if mask.width % 64 != 0 && len(row) > 0 {
+		row[len(row) - 1] &= ^(^uint64(0) << uint(mask.width % 64))
+	}
+}
\ No newline at end of file
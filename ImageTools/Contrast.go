@@ -0,0 +1,265 @@
+package ImageTools
+
+import (
+	"errors"
+	"math"
+	"sync"
+)
+
+/*
+ * Applies contrast-limited adaptive histogram equalisation (CLAHE) to a normalised (0-1) image (https://doi.org/10.1016/B978-0-12-336156-1.50061-6)
+ * The image is split into a tilesX x tilesY grid, and each tile's histogram of bins bins is equalised separately. Pixels are mapped by bilinearly interpolating between the mappings of the 4 nearest tiles, so there are no seams
+ * clipLimit limits how much the contrast can be stretched. No bin can have more than clipLimit times the average bin count, and the excess is spread over all the bins. 0 means no limit (plain adaptive histogram equalisation)
+ * Pixels outside 0-1 are counted in the first or last bin
+ */
+func CLAHE(image [][]float32, tilesX int, tilesY int, clipLimit float32, bins int) ([][]float32, error) {
+
+	// Check the parameters
+	imageWidth, imageHeight := Dimensions(image)
+	if tilesX < 1 || tilesY < 1 || tilesX > imageWidth || tilesY > imageHeight {
+		return nil, errors.New("Tile grid must be at least 1x1 and no bigger than the image")
+	}
+	if bins < 2 {
+		return nil, errors.New("There must be at least 2 bins")
+	}
+	if clipLimit < 0 {
+		return nil, errors.New("Clip limit must not be negative")
+	}
+
+	// Tile t covers the pixels from tileStart(t) up to tileStart(t + 1)
+	tileStartX := func(t int) int { return t * imageWidth / tilesX }
+	tileStartY := func(t int) int { return t * imageHeight / tilesY }
+
+	// Pixels are binned the same way as the tiles' histograms
+	bin := (&Histogram{Counts: make([]float64, bins), Min: 0, Max: 1}).Bin
+
+	// Build the mapping for each tile, one goroutine per tile
+	mappings := make([][][]float32, tilesX)
+	for tI := range mappings {
+		mappings[tI] = make([][]float32, tilesY)
+	}
+	var waitGroup sync.WaitGroup
+	waitGroup.Add(tilesX * tilesY)
+	for tJ := 0; tJ < tilesY; tJ++ {
+		for tI := 0; tI < tilesX; tI++ {
+			go func(tI int, tJ int) {
+				defer waitGroup.Done()
+
+				// Calculate the tile's histogram
+				tile := SubImage(image, tileStartX(tI), tileStartY(tJ), tileStartX(tI + 1) - tileStartX(tI), tileStartY(tJ + 1) - tileStartY(tJ))
+				histogram, _ := NewHistogram(tile, bins, 0, 1, nil)
+				count := histogram.Total()
+
+				// Clip the histogram and spread the excess evenly over every bin
+				if clipLimit > 0 {
+					limit := float64(clipLimit) * count / float64(bins)
+					excess := float64(0)
+					for b, binCount := range histogram.Counts {
+						if binCount > limit {
+							excess += binCount - limit
+							histogram.Counts[b] = limit
+						}
+					}
+					for b := range histogram.Counts {
+						histogram.Counts[b] += excess / float64(bins)
+					}
+				}
+
+				// Equalise, mapping each bin to the fraction of the tile that is at or below it
+				mapping := make([]float32, bins)
+				for b, cumulative := range histogram.Cumulative() {
+					mapping[b] = float32(cumulative / count)
+				}
+				mappings[tI][tJ] = mapping
+			} (tI, tJ)
+		}
+	}
+	waitGroup.Wait()
+
+	// Finds the two tiles whose centres are either side of a pixel, and how far the pixel is between them
+	neighbouringTiles := func(position int, size int, tiles int) (int, int, float64) {
+		tile := (float64(position) + 0.5) * float64(tiles) / float64(size) - 0.5
+		first := int(math.Floor(tile))
+		weight := tile - float64(first)
+		if first < 0 {
+			return 0, 0, 0
+		}
+		if first >= tiles - 1 {
+			return tiles - 1, tiles - 1, 0
+		}
+		return first, first + 1, weight
+	}
+
+	// Create output image
+	outputImage := make([][]float32, imageWidth)
+	for j := range outputImage {
+		outputImage[j] = make([]float32, imageHeight)
+	}
+
+	// Create wait group
+	waitGroup.Add(imageHeight)
+
+	// Iterate over columns
+	for j := 0; j < imageHeight; j++ {
+
+		// Process each row on its own goroutine
+		go func(j int) {
+			defer waitGroup.Done()
+			top, bottom, weightY := neighbouringTiles(j, imageHeight, tilesY)
+
+			// Iterate over row
+			for i := 0; i < imageWidth; i++ {
+				left, right, weightX := neighbouringTiles(i, imageWidth, tilesX)
+				b := bin(image[i][j])
+
+				// Bilinearly interpolate between the 4 tiles' mappings
+				topValue := (1 - weightX) * float64(mappings[left][top][b]) + weightX * float64(mappings[right][top][b])
+				bottomValue := (1 - weightX) * float64(mappings[left][bottom][b]) + weightX * float64(mappings[right][bottom][b])
+				outputImage[i][j] = float32((1 - weightY) * topValue + weightY * bottomValue)
+			}
+		} (j)
+	}
+
+	// Wait for all goroutines to finish
+	waitGroup.Wait()
+
+	return outputImage, nil
+}
+
+/*
+ * Equalises the histogram of an image, so that its pixel values are spread evenly over 0-1
+ * Each pixel is mapped to the fraction of the image that is darker than it, using a histogram of bins bins covering the image's range
+ * A flat image is returned unchanged
+ */
+func HistogramEqualisation(image [][]float32, bins int) ([][]float32, error) {
+
+	imageWidth, imageHeight := Dimensions(image)
+
+	// Calculate the histogram over the image's range
+	min, max := MinMax(image)
+	if max <= min {
+		outputImage := make([][]float32, imageWidth)
+		for i := range outputImage {
+			outputImage[i] = make([]float32, imageHeight)
+			copy(outputImage[i], image[i])
+		}
+		return outputImage, nil
+	}
+	histogram, err := NewHistogram(image, bins, min, max, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	cdf := histogram.CDFFunction()
+	return pointOperation(image, func(value float32) float32 {
+		return float32(cdf(value))
+	}), nil
+}
+
+/*
+ * Applies a function to every pixel of an image
+ */
+func pointOperation(image [][]float32, operation func(value float32) float32) [][]float32 {
+
+	imageWidth, imageHeight := Dimensions(image)
+
+	// Create output image
+	outputImage := make([][]float32, imageWidth)
+	for j := range outputImage {
+		outputImage[j] = make([]float32, imageHeight)
+	}
+
+	// Create wait group
+	var waitGroup sync.WaitGroup
+	waitGroup.Add(imageHeight)
+
+	// Iterate over columns
+	for j := 0; j < imageHeight; j++ {
+
+		// Process each row on its own goroutine
+		go func(j int) {
+			defer waitGroup.Done()
+
+			// Iterate over row
+			for i := 0; i < imageWidth; i++ {
+
+				// Calculate new pixel value
+				outputImage[i][j] = operation(image[i][j])
+			}
+		} (j)
+	}
+
+	// Wait for all goroutines to finish
+	waitGroup.Wait()
+
+	return outputImage
+}
+
+/*
+ * Remaps an image's pixel values so that its histogram matches a reference image's (histogram specification)
+ * Each pixel goes to the reference value with the same cumulative fraction, so the order of the pixels is kept
+ * Both histograms have bins bins covering their own image's range
+ */
+func MatchHistogram(image [][]float32, reference [][]float32, bins int) ([][]float32, error) {
+	min, max := MinMax(reference)
+	if max <= min {
+
+		// Every pixel has to go to the reference's one value
+		return pointOperation(image, func(value float32) float32 {
+			return min
+		}), nil
+	}
+	target, err := NewHistogram(reference, bins, min, max, nil)
+	if err != nil {
+		return nil, err
+	}
+	return MatchHistogramTo(image, target, bins)
+}
+
+/*
+ * Remaps an image's pixel values so that its histogram matches a target histogram (histogram specification)
+ * The target can come from NewHistogram, or be built directly from its Counts, Min and Max
+ * The image's histogram has bins bins covering its own range
+ */
+func MatchHistogramTo(image [][]float32, target *Histogram, bins int) ([][]float32, error) {
+
+	// Check the target
+	if target.Bins() < 1 || target.Max <= target.Min || target.Total() <= 0 {
+		return nil, errors.New("Target histogram must have at least 1 bin, a non-empty range and a positive total")
+	}
+
+	// A flat image has no order to keep, so it goes to the target's median
+	min, max := MinMax(image)
+	if max <= min {
+		median := target.InverseCDF(0.5)
+		return pointOperation(image, func(value float32) float32 {
+			return median
+		}), nil
+	}
+	source, err := NewHistogram(image, bins, min, max, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Map the edges of the source bins through the source CDF and the target's inverse CDF
+	// The source CDF is linear within each bin, so pixels can be interpolated between the edges
+	cdf := source.CDFFunction()
+	binWidth := source.BinWidth()
+	edges := make([]float32, bins + 1)
+	for b := range edges {
+		edges[b] = target.InverseCDF(cdf(min + float32(b) * binWidth))
+	}
+
+	return pointOperation(image, func(value float32) float32 {
+		position := float64(value - min) / float64(binWidth)
+		b := clamp(int(position), bins)
+		fraction := float32(position) - float32(b)
+		if fraction < 0 {
+			fraction = 0
+		}
+		if fraction > 1 {
+			fraction = 1
+		}
+		return edges[b] + fraction * (edges[b + 1] - edges[b])
+	}), nil
+}
\ No newline at end of file
@@ -0,0 +1,162 @@
+package ImageTools
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+/*
+ * A corner found by DetectCorners
+ */
+type Corner struct {
+	X, Y  int
+	Score float32
+}
+
+/*
+ * Calculates the Harris corner response det(M) - k * trace(M)^2 at every pixel (http://www.bmva.org/bmvc/1988/avc-88-023.pdf)
+ * M is the structure tensor, with the gradient products averaged by a Gaussian with standard deviation integrationScale
+ * k is usually between 0.04 and 0.06. Corners are strongly positive, edges are negative and flat regions are close to 0
+ */
+func HarrisResponse(image [][]float32, integrationScale float32, k float32) [][]float32 {
+	xx, xy, yy := structureTensor(image, SobelOperator, integrationScale)
+	return tensorMap(xx, xy, yy, func(a float64, b float64, c float64) float64 {
+		determinant, trace := a * c - b * b, a + c
+		return determinant - float64(k) * trace * trace
+	})
+}
+
+/*
+ * Calculates the Shi-Tomasi corner response (the smaller eigenvalue of the structure tensor) at every pixel (https://doi.org/10.1109/CVPR.1994.323794)
+ * The gradient products are averaged by a Gaussian with standard deviation integrationScale
+ */
+func ShiTomasiResponse(image [][]float32, integrationScale float32) [][]float32 {
+	xx, xy, yy := structureTensor(image, SobelOperator, integrationScale)
+	return tensorMap(xx, xy, yy, func(a float64, b float64, c float64) float64 {
+		_, smaller := eigenvalues(a, b, c)
+		return smaller
+	})
+}
+
+/*
+ * Finds the corners in a corner response map (such as HarrisResponse or ShiTomasiResponse), strongest first
+ * Only local maxima in each pixel's 8-neighbourhood that are at least qualityLevel times the strongest response are kept
+ * Corners closer than minDistance pixels to a stronger corner are then thrown away, and at most maxCorners are returned (0 means no limit)
+ */
+func DetectCorners(response [][]float32, qualityLevel float32, minDistance float32, maxCorners int) []Corner {
+
+	imageWidth, imageHeight := Dimensions(response)
+	_, max := MinMax(response)
+	threshold := qualityLevel * max
+	if threshold <= 0 {
+		threshold = math.SmallestNonzeroFloat32
+	}
+
+	// Find the candidates, strongest first
+	ranked := localMaxima(response, threshold)
+
+	// Greedily accept corners that aren't too close to one that has already been accepted
+	// Accepted corners are put into a grid of minDistance sized cells, so only the neighbouring cells have to be checked
+	var corners []Corner
+	cellSize := float64(minDistance)
+	if cellSize < 1 {
+		cellSize = 1
+	}
+	gridWidth, gridHeight := int(float64(imageWidth) / cellSize) + 1, int(float64(imageHeight) / cellSize) + 1
+	grid := make([][]Corner, gridWidth * gridHeight)
+	minDistanceSquared := float64(minDistance) * float64(minDistance)
+	for _, candidate := range ranked {
+		cellX, cellY := int(float64(candidate.X) / cellSize), int(float64(candidate.Y) / cellSize)
+		tooClose := false
+		for cJ := cellY - 1; cJ <= cellY + 1 && !tooClose; cJ++ {
+			for cI := cellX - 1; cI <= cellX + 1 && !tooClose; cI++ {
+				if cI < 0 || cI >= gridWidth || cJ < 0 || cJ >= gridHeight {
+					continue
+				}
+				for _, accepted := range grid[cJ * gridWidth + cI] {
+					dx, dy := float64(candidate.X - accepted.X), float64(candidate.Y - accepted.Y)
+					if dx * dx + dy * dy < minDistanceSquared {
+						tooClose = true
+						break
+					}
+				}
+			}
+		}
+		if tooClose {
+			continue
+		}
+
+		corners = append(corners, candidate)
+		grid[cellY * gridWidth + cellX] = append(grid[cellY * gridWidth + cellX], candidate)
+		if maxCorners > 0 && len(corners) >= maxCorners {
+			break
+		}
+	}
+
+	return corners
+}
+
+/*
+ * Finds the pixels that are at least threshold and are local maxima in their 8-neighbourhood, strongest first
+ */
+func localMaxima(image [][]float32, threshold float32) []Corner {
+
+	imageWidth, imageHeight := Dimensions(image)
+
+	// Find the local maxima in each row
+	candidates := make([][]Corner, imageHeight)
+
+	// Create wait group
+	var waitGroup sync.WaitGroup
+	waitGroup.Add(imageHeight)
+
+	// Iterate over columns
+	for j := 0; j < imageHeight; j++ {
+
+		// Process each row on its own goroutine
+		go func(j int) {
+			defer waitGroup.Done()
+
+			// Iterate over row
+			for i := 0; i < imageWidth; i++ {
+				currentPixel := image[i][j]
+				if currentPixel < threshold {
+					continue
+				}
+
+				// Check the 8-neighbourhood. Ties are broken by position, so a plateau only gives one maximum
+				isMaximum := true
+				for nJ := -1; nJ < 2 && isMaximum; nJ++ {
+					for nI := -1; nI < 2; nI++ {
+						x, y := i + nI, j + nJ
+						if (nI == 0 && nJ == 0) || x < 0 || x >= imageWidth || y < 0 || y >= imageHeight {
+							continue
+						}
+						if image[x][y] > currentPixel || (image[x][y] == currentPixel && (nJ < 0 || (nJ == 0 && nI < 0))) {
+							isMaximum = false
+							break
+						}
+					}
+				}
+				if isMaximum {
+					candidates[j] = append(candidates[j], Corner{X: i, Y: j, Score: currentPixel})
+				}
+			}
+		} (j)
+	}
+
+	// Wait for all goroutines to finish
+	waitGroup.Wait()
+
+	// Rank the maxima, strongest first
+	var ranked []Corner
+	for _, row := range candidates {
+		ranked = append(ranked, row...)
+	}
+	sort.SliceStable(ranked, func(a int, b int) bool {
+		return ranked[a].Score > ranked[b].Score
+	})
+
+	return ranked
+}
\ No newline at end of file
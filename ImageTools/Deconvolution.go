@@ -0,0 +1,373 @@
+package ImageTools
+
+import (
+	"errors"
+	"math"
+	"math/cmplx"
+	"sync"
+)
+
+/*
+ * Deblurs an image with a Wiener filter, given the point spread function (PSF) that blurred it
+ * The PSF is in the same format as the kernels package, so an image blurred by Convolution(original, psf, false) can be recovered
+ * noiseToSignal is the expected noise to signal power ratio. 0 gives a plain inverse filter, and bigger values trade sharpness for less noise and ringing
+ * The image is padded by repeating its edge pixels, to reduce ringing at the border
+ */
+func WienerDeconvolution(image [][]float32, psf [][]float32, noiseToSignal float32) ([][]float32, error) {
+
+	// Check the parameters
+	imageWidth, imageHeight := Dimensions(image)
+	psfWidth, psfHeight := Dimensions(psf)
+	if psfWidth > imageWidth || psfHeight > imageHeight {
+		return nil, errors.New("PSF is bigger than the image")
+	}
+	if noiseToSignal < 0 {
+		return nil, errors.New("Noise to signal ratio must not be negative")
+	}
+
+	// Pad everything up to a power of 2, with enough room that the blur doesn't wrap round
+	paddedWidth, paddedHeight := nextPowerOfTwo(imageWidth + psfWidth), nextPowerOfTwo(imageHeight + psfHeight)
+	padX, padY := psfWidth, psfHeight
+
+	// Copy the image into the middle of the padded array, repeating the edge pixels outwards
+	blurred := make([][]complex128, paddedWidth)
+	for i := range blurred {
+		blurred[i] = make([]complex128, paddedHeight)
+		for j := range blurred[i] {
+			x := clamp(i - padX, imageWidth)
+			y := clamp(j - padY, imageHeight)
+			blurred[i][j] = complex(float64(image[x][y]), 0)
+		}
+	}
+
+	// Convolution correlates the image with the kernel, which is the same as convolving it with the kernel flipped round its centre
+	// So element n of the kernel goes at offset (half - n), wrapped round
+	transfer := make([][]complex128, paddedWidth)
+	for i := range transfer {
+		transfer[i] = make([]complex128, paddedHeight)
+	}
+	for kI := 0; kI < psfWidth; kI++ {
+		for kJ := 0; kJ < psfHeight; kJ++ {
+			x := (psfWidth / 2 - kI + paddedWidth) % paddedWidth
+			y := (psfHeight / 2 - kJ + paddedHeight) % paddedHeight
+			transfer[x][y] = complex(float64(psf[kI][kJ]), 0)
+		}
+	}
+
+	// Transform both on their own goroutines
+	done := make(chan bool)
+	go func() { fft2D(blurred, false); done <- true }()
+	go func() { fft2D(transfer, false); done <- true }()
+	<- done
+	<- done
+
+	// Apply the Wiener filter, conj(H) / (|H|^2 + NSR)
+	var waitGroup sync.WaitGroup
+	waitGroup.Add(paddedWidth)
+	for i := 0; i < paddedWidth; i++ {
+		go func(i int) {
+			defer waitGroup.Done()
+			for j := 0; j < paddedHeight; j++ {
+				h := transfer[i][j]
+				power := real(h) * real(h) + imag(h) * imag(h)
+				if power + float64(noiseToSignal) == 0 {
+					blurred[i][j] = 0
+					continue
+				}
+				blurred[i][j] *= cmplx.Conj(h) / complex(power + float64(noiseToSignal), 0)
+			}
+		} (i)
+	}
+	waitGroup.Wait()
+
+	// Transform back
+	fft2D(blurred, true)
+
+	// Create output image, cropping the padding off
+	outputImage := make([][]float32, imageWidth)
+	for i := range outputImage {
+		outputImage[i] = make([]float32, imageHeight)
+		for j := range outputImage[i] {
+			outputImage[i][j] = float32(real(blurred[i + padX][j + padY]))
+		}
+	}
+
+	return outputImage, nil
+}
+
+/*
+ * Deblurs an image with the Richardson-Lucy algorithm, given the point spread function (PSF) that blurred it (https://doi.org/10.1364/JOSA.62.000055)
+ * The PSF is in the same format as the kernels package, so an image blurred by Convolution(original, psf, false) can be recovered. It should have an odd width and height
+ * Each iteration blurs the current estimate, compares it with the image, and corrects the estimate. More iterations give a sharper result, but amplify noise
+ * If tvWeight is greater than 0, total variation regularisation is applied, which suppresses noise while keeping edges (https://doi.org/10.1002/jemt.20294). Values around 0.002 work well
+ * Pixel values must not be negative
+ */
+func RichardsonLucy(image [][]float32, psf [][]float32, iterations int, tvWeight float32) ([][]float32, error) {
+
+	// Check the parameters
+	imageWidth, imageHeight := Dimensions(image)
+	psfWidth, psfHeight := Dimensions(psf)
+	if psfWidth > imageWidth || psfHeight > imageHeight {
+		return nil, errors.New("PSF is bigger than the image")
+	}
+	if psfWidth % 2 == 0 || psfHeight % 2 == 0 {
+		return nil, errors.New("PSF must have an odd width and height")
+	}
+	if tvWeight < 0 {
+		return nil, errors.New("Total variation weight must not be negative")
+	}
+
+	// Normalise a copy of the PSF, so that the algorithm preserves brightness
+	psfSum := float64(0)
+	for kI := range psf {
+		for kJ := range psf[kI] {
+			psfSum += float64(psf[kI][kJ])
+		}
+	}
+	if psfSum <= 0 {
+		return nil, errors.New("PSF must have a positive sum")
+	}
+
+	// The adjoint of the blur is a convolution with the PSF flipped round its centre
+	normalisedPSF := make([][]float32, psfWidth)
+	flippedPSF := make([][]float32, psfWidth)
+	for kI := range psf {
+		normalisedPSF[kI] = make([]float32, psfHeight)
+		flippedPSF[psfWidth - 1 - kI] = make([]float32, psfHeight)
+	}
+	for kI := range psf {
+		for kJ := range psf[kI] {
+			normalisedPSF[kI][kJ] = float32(float64(psf[kI][kJ]) / psfSum)
+			flippedPSF[psfWidth - 1 - kI][psfHeight - 1 - kJ] = normalisedPSF[kI][kJ]
+		}
+	}
+
+	// Near the border, part of the PSF falls off the edge of the image, so work out how much of it is left at each pixel
+	ones := make([][]float32, imageWidth)
+	for i := range ones {
+		ones[i] = make([]float32, imageHeight)
+		for j := range ones[i] {
+			ones[i][j] = 1
+		}
+	}
+	coverage := Convolution(ones, flippedPSF, false)
+
+	// Start from the blurred image
+	estimate := make([][]float32, imageWidth)
+	for i := range estimate {
+		estimate[i] = make([]float32, imageHeight)
+		copy(estimate[i], image[i])
+	}
+
+	for iteration := 0; iteration < iterations; iteration++ {
+
+		// Compare the blurred estimate with the image
+		reblurred := Convolution(estimate, normalisedPSF, false)
+		ratio := make([][]float32, imageWidth)
+		for i := range ratio {
+			ratio[i] = make([]float32, imageHeight)
+		}
+		var waitGroup sync.WaitGroup
+		waitGroup.Add(imageHeight)
+		for j := 0; j < imageHeight; j++ {
+			go func(j int) {
+				defer waitGroup.Done()
+				for i := 0; i < imageWidth; i++ {
+					if reblurred[i][j] > 1e-12 {
+						ratio[i][j] = image[i][j] / reblurred[i][j]
+					}
+				}
+			} (j)
+		}
+		waitGroup.Wait()
+		correction := Convolution(ratio, flippedPSF, false)
+
+		// Calculate the total variation term, div(grad u / |grad u|)
+		var divergence [][]float32
+		if tvWeight > 0 {
+			divergence = normalisedGradientDivergence(estimate)
+		}
+
+		// Update the estimate
+		waitGroup.Add(imageHeight)
+		for j := 0; j < imageHeight; j++ {
+			go func(j int) {
+				defer waitGroup.Done()
+				for i := 0; i < imageWidth; i++ {
+					updated := float64(estimate[i][j]) * float64(correction[i][j]) / float64(coverage[i][j])
+					if tvWeight > 0 {
+
+						// Stop the regularisation term from blowing up, or flipping the sign of the pixel
+						denominator := 1 - float64(tvWeight) * float64(divergence[i][j])
+						if denominator < 0.1 {
+							denominator = 0.1
+						}
+						updated /= denominator
+					}
+					estimate[i][j] = float32(updated)
+				}
+			} (j)
+		}
+		waitGroup.Wait()
+	}
+
+	return estimate, nil
+}
+
+/*
+ * Calculates div(grad u / |grad u|), the curvature term used for total variation regularisation
+ * Forward differences are used for the gradient and backward differences for the divergence, so the pair are adjoint
+ */
+func normalisedGradientDivergence(image [][]float32) [][]float32 {
+
+	imageWidth, imageHeight := Dimensions(image)
+	const epsilon = 1e-4
+
+	// Calculate the normalised forward difference gradient
+	nx := make([][]float32, imageWidth)
+	ny := make([][]float32, imageWidth)
+	for i := range nx {
+		nx[i] = make([]float32, imageHeight)
+		ny[i] = make([]float32, imageHeight)
+	}
+	var waitGroup sync.WaitGroup
+	waitGroup.Add(imageHeight)
+	for j := 0; j < imageHeight; j++ {
+		go func(j int) {
+			defer waitGroup.Done()
+			for i := 0; i < imageWidth; i++ {
+				dx, dy := float64(0), float64(0)
+				if i < imageWidth - 1 {
+					dx = float64(image[i + 1][j] - image[i][j])
+				}
+				if j < imageHeight - 1 {
+					dy = float64(image[i][j + 1] - image[i][j])
+				}
+				magnitude := math.Sqrt(dx * dx + dy * dy + epsilon * epsilon)
+				nx[i][j], ny[i][j] = float32(dx / magnitude), float32(dy / magnitude)
+			}
+		} (j)
+	}
+	waitGroup.Wait()
+
+	// Create output image
+	outputImage := make([][]float32, imageWidth)
+	for i := range outputImage {
+		outputImage[i] = make([]float32, imageHeight)
+	}
+
+	// Calculate the backward difference divergence
+	waitGroup.Add(imageHeight)
+	for j := 0; j < imageHeight; j++ {
+		go func(j int) {
+			defer waitGroup.Done()
+			for i := 0; i < imageWidth; i++ {
+				divergence := nx[i][j] + ny[i][j]
+				if i > 0 {
+					divergence -= nx[i - 1][j]
+				}
+				if j > 0 {
+					divergence -= ny[i][j - 1]
+				}
+				outputImage[i][j] = divergence
+			}
+		} (j)
+	}
+	waitGroup.Wait()
+
+	return outputImage
+}
+
+/*
+ * Performs an in-place 2D fast Fourier transform (or its inverse) of an array whose dimensions are both powers of 2
+ * The inverse is scaled by 1/N, so a forward transform followed by an inverse one gives back the original
+ */
+func fft2D(data [][]complex128, inverse bool) {
+
+	width, height := len(data), len(data[0])
+
+	// Transform along y, one goroutine per column
+	var waitGroup sync.WaitGroup
+	waitGroup.Add(width)
+	for i := 0; i < width; i++ {
+		go func(i int) {
+			defer waitGroup.Done()
+			fft(data[i], inverse)
+		} (i)
+	}
+	waitGroup.Wait()
+
+	// Transform along x, one goroutine per row
+	waitGroup.Add(height)
+	for j := 0; j < height; j++ {
+		go func(j int) {
+			defer waitGroup.Done()
+			row := make([]complex128, width)
+			for i := 0; i < width; i++ {
+				row[i] = data[i][j]
+			}
+			fft(row, inverse)
+			for i := 0; i < width; i++ {
+				data[i][j] = row[i]
+			}
+		} (j)
+	}
+	waitGroup.Wait()
+}
+
+/*
+ * Performs an in-place iterative radix-2 fast Fourier transform (or its inverse) of an array whose length is a power of 2
+ */
+func fft(data []complex128, inverse bool) {
+
+	n := len(data)
+
+	// Put the elements into bit-reversed order
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j & bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			data[i], data[j] = data[j], data[i]
+		}
+	}
+
+	// Combine ever bigger transforms
+	sign := -1.0
+	if inverse {
+		sign = 1.0
+	}
+	for length := 2; length <= n; length <<= 1 {
+		angle := sign * 2 * math.Pi / float64(length)
+		root := complex(math.Cos(angle), math.Sin(angle))
+		for start := 0; start < n; start += length {
+			twiddle := complex(1, 0)
+			for k := 0; k < length / 2; k++ {
+				even, odd := data[start + k], data[start + k + length / 2] * twiddle
+				data[start + k] = even + odd
+				data[start + k + length / 2] = even - odd
+				twiddle *= root
+			}
+		}
+	}
+
+	// Scale the inverse
+	if inverse {
+		for i := range data {
+			data[i] /= complex(float64(n), 0)
+		}
+	}
+}
+
+/*
+ * Returns the smallest power of 2 that is at least n
+ */
+func nextPowerOfTwo(n int) int {
+	power := 1
+	for power < n {
+		power <<= 1
+	}
+	return power
+}
\ No newline at end of file
@@ -0,0 +1,672 @@
+package ImageTools
+
+import (
+	"ImageTools/kernels"
+	"errors"
+	"math"
+	"sync"
+)
+
+/*
+ * Applies an edge-preserving bilateral filter to an image
+ * Each pixel becomes a weighted average of its neighbours, where the weights fall off with both spatial distance (spatialSigma, in pixels) and intensity difference (rangeSigma, in pixel value units)
+ * This is the exact (brute force) version, so it gets slow for large spatial sigmas. Use FastBilateralFilter for big images
+ */
+func BilateralFilter(image [][]float32, spatialSigma float32, rangeSigma float32) ([][]float32, error) {
+
+	// Check the parameters
+	if spatialSigma <= 0 {
+		return nil, errors.New("Spatial sigma must be greater than 0")
+	}
+	if rangeSigma <= 0 {
+		return nil, errors.New("Range sigma must be greater than 0")
+	}
+
+	imageWidth, imageHeight := Dimensions(image)
+
+	// The window extends 2 standard deviations either side of the centre pixel, which covers ~95% of the spatial weight
+	radius := int(math.Ceil(2 * float64(spatialSigma)))
+	if radius < 1 {
+		radius = 1
+	}
+
+	// Precompute the spatial weights, as they are the same for every pixel
+	spatialWeights := make([][]float64, radius + radius + 1)
+	spatialDenominator := 2 * float64(spatialSigma) * float64(spatialSigma)
+	for kI := range spatialWeights {
+		spatialWeights[kI] = make([]float64, radius + radius + 1)
+		for kJ := range spatialWeights[kI] {
+			dx, dy := float64(kI - radius), float64(kJ - radius)
+			spatialWeights[kI][kJ] = math.Exp(-(dx * dx + dy * dy) / spatialDenominator)
+		}
+	}
+	rangeDenominator := 2 * float64(rangeSigma) * float64(rangeSigma)
+
+	// Create output image
+	outputImage := make([][]float32, imageWidth)
+	for j := range outputImage {
+		outputImage[j] = make([]float32, imageHeight)
+	}
+
+	// Create wait group
+	var waitGroup sync.WaitGroup
+	waitGroup.Add(imageHeight)
+
+	// Iterate over columns
+	for j := 0; j < imageHeight; j++ {
+
+		// Process each row on its own goroutine
+		go func(j int) {
+			defer waitGroup.Done()
+
+			// Iterate over row
+			for i := 0; i < imageWidth; i++ {
+				centrePixel := float64(image[i][j])
+
+				// Calculate the weighted average of the neighbourhood, skipping any pixels that go off the edge of the image
+				accumulator, weightSum := float64(0), float64(0)
+				for kJ := -radius; kJ <= radius; kJ++ {
+					y := j + kJ
+					if y < 0 || y >= imageHeight {
+						continue
+					}
+					for kI := -radius; kI <= radius; kI++ {
+						x := i + kI
+						if x < 0 || x >= imageWidth {
+							continue
+						}
+						neighbourPixel := float64(image[x][y])
+						difference := neighbourPixel - centrePixel
+						weight := spatialWeights[kI + radius][kJ + radius] * math.Exp(-(difference * difference) / rangeDenominator)
+						accumulator += weight * neighbourPixel
+						weightSum += weight
+					}
+				}
+
+				// The centre pixel always has a weight of 1, so weightSum can never be zero
+				outputImage[i][j] = float32(accumulator / weightSum)
+			}
+		} (j)
+	}
+
+	// Wait for all goroutines to finish
+	waitGroup.Wait()
+
+	return outputImage, nil
+}
+
+/*
+ * The most cells FastBilateralFilter puts along the intensity axis of its grid
+ */
+const maxBilateralGridDepth = 256
+
+/*
+ * Applies a fast approximation of the bilateral filter using a bilateral grid (https://doi.org/10.1145/1276377.1276506)
+ * Pixels are splatted into a coarse 3D grid (x, y, intensity), the grid is blurred, and the output is sliced back out with trilinear interpolation
+ * The cost barely depends on the sigmas, so this is practical on megapixel images
+ * The grid has one cell per spatialSigma pixels, so spatial sigmas below 1 would not downsample at all. The exact BilateralFilter is used for those instead, as its window is tiny
+ * The intensity axis is capped at 256 cells, so range sigmas below 1/256 of the image's range are treated as 1/256 of it
+ */
+func FastBilateralFilter(image [][]float32, spatialSigma float32, rangeSigma float32) ([][]float32, error) {
+
+	// Check the parameters
+	if spatialSigma <= 0 {
+		return nil, errors.New("Spatial sigma must be greater than 0")
+	}
+	if rangeSigma <= 0 {
+		return nil, errors.New("Range sigma must be greater than 0")
+	}
+	if spatialSigma < 1 {
+		return BilateralFilter(image, spatialSigma, rangeSigma)
+	}
+
+	imageWidth, imageHeight := Dimensions(image)
+	min, max := MinMax(image)
+	spatialStep, rangeStep := float64(spatialSigma), float64(rangeSigma)
+	if minimumStep := float64(max - min) / maxBilateralGridDepth; rangeStep < minimumStep {
+		rangeStep = minimumStep
+	}
+
+	// Add padding around the grid so the blur never has to go off the edge
+	const padding = 2
+	gridWidth := int(float64(imageWidth - 1) / spatialStep + 0.5) + 1 + padding + padding
+	gridHeight := int(float64(imageHeight - 1) / spatialStep + 0.5) + 1 + padding + padding
+	gridDepth := int(float64(max - min) / rangeStep + 0.5) + 1 + padding + padding
+	grid := newBilateralGrid(gridWidth, gridHeight, gridDepth)
+
+	// Splat every pixel into its nearest grid cell
+	// Each goroutine owns one row of the grid, so no two goroutines ever write to the same cell
+	var waitGroup sync.WaitGroup
+	waitGroup.Add(gridHeight)
+	for gJ := 0; gJ < gridHeight; gJ++ {
+		go func(gJ int) {
+			defer waitGroup.Done()
+
+			// Find the image rows that round to this grid row
+			firstRow := int(math.Ceil((float64(gJ - padding) - 0.5) * spatialStep))
+			lastRow := int(math.Ceil((float64(gJ - padding) + 0.5) * spatialStep))
+			if firstRow < 0 {
+				firstRow = 0
+			}
+			if lastRow > imageHeight {
+				lastRow = imageHeight
+			}
+			for j := firstRow; j < lastRow; j++ {
+				if int(float64(j) / spatialStep + 0.5) + padding != gJ {
+					continue
+				}
+				for i := 0; i < imageWidth; i++ {
+					gI := int(float64(i) / spatialStep + 0.5) + padding
+					gK := int(float64(image[i][j] - min) / rangeStep + 0.5) + padding
+					index := grid.index(gI, gJ, gK)
+					grid.values[index] += float64(image[i][j])
+					grid.weights[index]++
+				}
+			}
+		} (gJ)
+	}
+	waitGroup.Wait()
+
+	// Blur the grid along each of its three axes
+	grid.blur()
+
+	// Create output image
+	outputImage := make([][]float32, imageWidth)
+	for j := range outputImage {
+		outputImage[j] = make([]float32, imageHeight)
+	}
+
+	// Slice the output back out of the grid
+	waitGroup.Add(imageHeight)
+
+	// Iterate over columns
+	for j := 0; j < imageHeight; j++ {
+
+		// Process each row on its own goroutine
+		go func(j int) {
+			defer waitGroup.Done()
+
+			// Iterate over row
+			for i := 0; i < imageWidth; i++ {
+				x := float64(i) / spatialStep + padding
+				y := float64(j) / spatialStep + padding
+				z := float64(image[i][j] - min) / rangeStep + padding
+				value, weight := grid.interpolate(x, y, z)
+				if weight > 0 {
+					outputImage[i][j] = float32(value / weight)
+				} else {
+					outputImage[i][j] = image[i][j]
+				}
+			}
+		} (j)
+	}
+
+	// Wait for all goroutines to finish
+	waitGroup.Wait()
+
+	return outputImage, nil
+}
+
+/*
+ * A 3D grid of homogeneous (value, weight) pairs used by FastBilateralFilter
+ */
+type bilateralGrid struct {
+	width, height, depth int
+	values, weights []float64
+}
+
+/*
+ * Creates an empty bilateral grid
+ */
+func newBilateralGrid(width int, height int, depth int) *bilateralGrid {
+	return &bilateralGrid{
+		width:   width,
+		height:  height,
+		depth:   depth,
+		values:  make([]float64, width * height * depth),
+		weights: make([]float64, width * height * depth),
+	}
+}
+
+/*
+ * Returns the index of a cell in the flattened grid
+ */
+func (grid *bilateralGrid) index(i int, j int, k int) int {
+	return (j * grid.width + i) * grid.depth + k
+}
+
+/*
+ * Blurs the grid with a [1 4 6 4 1] / 16 kernel along each axis (approximately a Gaussian with a standard deviation of 1 cell)
+ */
+func (grid *bilateralGrid) blur() {
+	kernel := [5]float64{1.0 / 16, 4.0 / 16, 6.0 / 16, 4.0 / 16, 1.0 / 16}
+
+	// Blurs a single line of cells, starting at the given index and moving by stride each step
+	blurLine := func(data []float64, scratch []float64, start int, stride int, length int) {
+		for n := 0; n < length; n++ {
+			scratch[n] = data[start + n * stride]
+		}
+		for n := 2; n < length - 2; n++ {
+			accumulator := float64(0)
+			for k := -2; k <= 2; k++ {
+				accumulator += kernel[k + 2] * scratch[n + k]
+			}
+			data[start + n * stride] = accumulator
+		}
+	}
+
+	// Blur along x and z. Each goroutine handles one row of the grid
+	var waitGroup sync.WaitGroup
+	waitGroup.Add(grid.height)
+	for j := 0; j < grid.height; j++ {
+		go func(j int) {
+			defer waitGroup.Done()
+			scratch := make([]float64, grid.width + grid.depth)
+			for _, data := range [][]float64{grid.values, grid.weights} {
+				for k := 0; k < grid.depth; k++ {
+					blurLine(data, scratch, grid.index(0, j, k), grid.depth, grid.width)
+				}
+				for i := 0; i < grid.width; i++ {
+					blurLine(data, scratch, grid.index(i, j, 0), 1, grid.depth)
+				}
+			}
+		} (j)
+	}
+	waitGroup.Wait()
+
+	// Blur along y. Each goroutine handles one column of the grid
+	waitGroup.Add(grid.width)
+	for i := 0; i < grid.width; i++ {
+		go func(i int) {
+			defer waitGroup.Done()
+			scratch := make([]float64, grid.height)
+			for _, data := range [][]float64{grid.values, grid.weights} {
+				for k := 0; k < grid.depth; k++ {
+					blurLine(data, scratch, grid.index(i, 0, k), grid.width * grid.depth, grid.height)
+				}
+			}
+		} (i)
+	}
+	waitGroup.Wait()
+}
+
+/*
+ * Samples the grid at a fractional position using trilinear interpolation
+ */
+func (grid *bilateralGrid) interpolate(x float64, y float64, z float64) (float64, float64) {
+	i, j, k := int(x), int(y), int(z)
+	if i >= grid.width - 1 {
+		i = grid.width - 2
+	}
+	if j >= grid.height - 1 {
+		j = grid.height - 2
+	}
+	if k >= grid.depth - 1 {
+		k = grid.depth - 2
+	}
+	fx, fy, fz := x - float64(i), y - float64(j), z - float64(k)
+
+	value, weight := float64(0), float64(0)
+	for dk := 0; dk < 2; dk++ {
+		for dj := 0; dj < 2; dj++ {
+			for di := 0; di < 2; di++ {
+				factor := (1 - fx) + float64(di) * (2 * fx - 1)
+				factor *= (1 - fy) + float64(dj) * (2 * fy - 1)
+				factor *= (1 - fz) + float64(dk) * (2 * fz - 1)
+				index := grid.index(i + di, j + dj, k + dk)
+				value += factor * grid.values[index]
+				weight += factor * grid.weights[index]
+			}
+		}
+	}
+	return value, weight
+}
+
+/*
+ * Denoises an image with non-local means (https://doi.org/10.1109/CVPR.2005.38)
+ * Every pixel becomes a weighted average of the pixels in a search window around it, weighted by how similar the patches around the two pixels are
+ * patchRadius and searchRadius are in pixels, and h controls the filtering strength (bigger means smoother)
+ * This is the brute force version. FastNonLocalMeans gives the same result using integral images
+ */
+func NonLocalMeans(image [][]float32, patchRadius int, searchRadius int, h float32) ([][]float32, error) {
+
+	// Check the parameters
+	if h <= 0 {
+		return nil, errors.New("h must be greater than 0")
+	}
+	if patchRadius < 0 || searchRadius < 0 {
+		return nil, errors.New("Radii must not be negative")
+	}
+
+	imageWidth, imageHeight := Dimensions(image)
+	hSquared := float64(h) * float64(h)
+
+	// Create output image
+	outputImage := make([][]float32, imageWidth)
+	for j := range outputImage {
+		outputImage[j] = make([]float32, imageHeight)
+	}
+
+	// Create wait group
+	var waitGroup sync.WaitGroup
+	waitGroup.Add(imageHeight)
+
+	// Iterate over columns
+	for j := 0; j < imageHeight; j++ {
+
+		// Process each row on its own goroutine
+		go func(j int) {
+			defer waitGroup.Done()
+
+			// Iterate over row
+			for i := 0; i < imageWidth; i++ {
+
+				// Compare the patch around this pixel with the patch around every other pixel in the search window
+				accumulator, weightSum := float64(0), float64(0)
+				for dJ := -searchRadius; dJ <= searchRadius; dJ++ {
+					for dI := -searchRadius; dI <= searchRadius; dI++ {
+						if i + dI < 0 || i + dI >= imageWidth || j + dJ < 0 || j + dJ >= imageHeight {
+							continue
+						}
+
+						// Mean square difference between the two patches
+						distance, count := float64(0), 0
+						for pJ := j - patchRadius; pJ <= j + patchRadius; pJ++ {
+							if pJ < 0 || pJ >= imageHeight {
+								continue
+							}
+							for pI := i - patchRadius; pI <= i + patchRadius; pI++ {
+								if pI < 0 || pI >= imageWidth {
+									continue
+								}
+								difference := float64(image[pI][pJ]) - float64(image[clamp(pI + dI, imageWidth)][clamp(pJ + dJ, imageHeight)])
+								distance += difference * difference
+								count++
+							}
+						}
+
+						weight := math.Exp(-(distance / float64(count)) / hSquared)
+						accumulator += weight * float64(image[i + dI][j + dJ])
+						weightSum += weight
+					}
+				}
+
+				outputImage[i][j] = float32(accumulator / weightSum)
+			}
+		} (j)
+	}
+
+	// Wait for all goroutines to finish
+	waitGroup.Wait()
+
+	return outputImage, nil
+}
+
+/*
+ * Denoises an image with non-local means, using integral images to speed things up (https://doi.org/10.1016/j.imavis.2008.04.004)
+ * For each offset in the search window, the squared difference between the image and the shifted image is summed into an integral image
+ * Every patch distance for that offset can then be read out in constant time, so the cost no longer depends on the patch size
+ */
+func FastNonLocalMeans(image [][]float32, patchRadius int, searchRadius int, h float32) ([][]float32, error) {
+
+	// Check the parameters
+	if h <= 0 {
+		return nil, errors.New("h must be greater than 0")
+	}
+	if patchRadius < 0 || searchRadius < 0 {
+		return nil, errors.New("Radii must not be negative")
+	}
+
+	imageWidth, imageHeight := Dimensions(image)
+	hSquared := float64(h) * float64(h)
+
+	// Running totals for every pixel
+	accumulators := make([][]float64, imageWidth)
+	weightSums := make([][]float64, imageWidth)
+	for i := range accumulators {
+		accumulators[i] = make([]float64, imageHeight)
+		weightSums[i] = make([]float64, imageHeight)
+	}
+
+	// Integral image of the squared differences, with an extra row and column of zeros at the start
+	integral := make([][]float64, imageWidth + 1)
+	for i := range integral {
+		integral[i] = make([]float64, imageHeight + 1)
+	}
+
+	var waitGroup sync.WaitGroup
+
+	// Iterate over every offset in the search window
+	for dJ := -searchRadius; dJ <= searchRadius; dJ++ {
+		for dI := -searchRadius; dI <= searchRadius; dI++ {
+
+			// Sum the squared differences along each row, one goroutine per row
+			waitGroup.Add(imageHeight)
+			for j := 0; j < imageHeight; j++ {
+				go func(j int) {
+					defer waitGroup.Done()
+					rowSum := float64(0)
+					for i := 0; i < imageWidth; i++ {
+						difference := float64(image[i][j]) - float64(image[clamp(i + dI, imageWidth)][clamp(j + dJ, imageHeight)])
+						rowSum += difference * difference
+						integral[i + 1][j + 1] = rowSum
+					}
+				} (j)
+			}
+			waitGroup.Wait()
+
+			// Then sum down each column, one goroutine per column
+			waitGroup.Add(imageWidth)
+			for i := 1; i <= imageWidth; i++ {
+				go func(i int) {
+					defer waitGroup.Done()
+					for j := 1; j <= imageHeight; j++ {
+						integral[i][j] += integral[i][j - 1]
+					}
+				} (i)
+			}
+			waitGroup.Wait()
+
+			// Read out the patch distances and accumulate the weighted pixels
+			waitGroup.Add(imageHeight)
+			for j := 0; j < imageHeight; j++ {
+				go func(j int) {
+					defer waitGroup.Done()
+					if j + dJ < 0 || j + dJ >= imageHeight {
+						return
+					}
+					top, bottom := clamp(j - patchRadius, imageHeight), clamp(j + patchRadius, imageHeight) + 1
+					for i := 0; i < imageWidth; i++ {
+						if i + dI < 0 || i + dI >= imageWidth {
+							continue
+						}
+						left, right := clamp(i - patchRadius, imageWidth), clamp(i + patchRadius, imageWidth) + 1
+						distance := integral[right][bottom] - integral[left][bottom] - integral[right][top] + integral[left][top]
+						count := float64((right - left) * (bottom - top))
+						weight := math.Exp(-(distance / count) / hSquared)
+						accumulators[i][j] += weight * float64(image[i + dI][j + dJ])
+						weightSums[i][j] += weight
+					}
+				} (j)
+			}
+			waitGroup.Wait()
+		}
+	}
+
+	// Create output image
+	outputImage := make([][]float32, imageWidth)
+	for j := range outputImage {
+		outputImage[j] = make([]float32, imageHeight)
+	}
+
+	// Divide through by the sum of the weights
+	waitGroup.Add(imageHeight)
+	for j := 0; j < imageHeight; j++ {
+		go func(j int) {
+			defer waitGroup.Done()
+			for i := 0; i < imageWidth; i++ {
+				outputImage[i][j] = float32(accumulators[i][j] / weightSums[i][j])
+			}
+		} (j)
+	}
+	waitGroup.Wait()
+
+	return outputImage, nil
+}
+
+/*
+ * A Perona-Malik conduction function, which maps a gradient magnitude to a diffusion coefficient between 0 and 1
+ * Kappa sets the gradient magnitude that counts as an edge
+ */
+type ConductionFunction func(gradient float64, kappa float64) float64
+
+/*
+ * The first Perona-Malik conduction function, exp(-(|grad I| / kappa)^2)
+ * Favours high contrast edges over low contrast ones
+ */
+func ExponentialConduction(gradient float64, kappa float64) float64 {
+	ratio := gradient / kappa
+	return math.Exp(-ratio * ratio)
+}
+
+/*
+ * The second Perona-Malik conduction function, 1 / (1 + (|grad I| / kappa)^2)
+ * Favours wide regions over smaller ones
+ */
+func QuadraticConduction(gradient float64, kappa float64) float64 {
+	ratio := gradient / kappa
+	return 1 / (1 + ratio * ratio)
+}
+
+/*
+ * Called after every iteration of AnisotropicDiffusion with the iteration number (starting at 1), a copy of the current image and the RMS change made by that iteration
+ * Return false to stop diffusing early
+ */
+type DiffusionCallback func(iteration int, image [][]float32, change float32) bool
+
+/*
+ * Smooths an image with Perona-Malik anisotropic diffusion (https://doi.org/10.1109/34.56205)
+ * Homogeneous regions are smoothed, but diffusion is slowed down across edges, so they are preserved
+ * The gradient magnitude that drives the conduction function is measured with the Sobel kernels
+ * timeStep must be in the range 0-0.25 (inclusive) for the scheme to be stable. The callback is optional (pass nil)
+ */
+func AnisotropicDiffusion(image [][]float32, iterations int, kappa float32, timeStep float32, conduction ConductionFunction, callback DiffusionCallback) ([][]float32, error) {
+
+	// Check the parameters
+	if timeStep <= 0 || timeStep > 0.25 {
+		return nil, errors.New("Time step must be greater than 0 and no more than 0.25")
+	}
+	if kappa <= 0 {
+		return nil, errors.New("Kappa must be greater than 0")
+	}
+	if conduction == nil {
+		return nil, errors.New("No conduction function")
+	}
+	imageWidth, imageHeight := Dimensions(image)
+
+	// Copy the image, so the original isn't modified
+	current := make([][]float32, imageWidth)
+	for i := range current {
+		current[i] = make([]float32, imageHeight)
+		copy(current[i], image[i])
+	}
+
+	// Create output image
+	next := make([][]float32, imageWidth)
+	for j := range next {
+		next[j] = make([]float32, imageHeight)
+	}
+
+	for iteration := 1; iteration <= iterations; iteration++ {
+
+		// Calculate the gradients. The Sobel kernels sum to 8 times the central difference, so divide that back out
+		ch := make(chan [][]float32)
+		go func() { ch <- Convolution(current, kernels.SobelX, false) }()
+		go func() { ch <- Convolution(current, kernels.SobelY, false) }()
+		a := <- ch
+		b := <- ch
+
+		// Calculate the diffusion coefficient at every pixel
+		coefficients := make([][]float64, imageWidth)
+		for i := range coefficients {
+			coefficients[i] = make([]float64, imageHeight)
+		}
+		var waitGroup sync.WaitGroup
+		waitGroup.Add(imageHeight)
+		for j := 0; j < imageHeight; j++ {
+			go func(j int) {
+				defer waitGroup.Done()
+				for i := 0; i < imageWidth; i++ {
+					gradient := math.Hypot(float64(a[i][j]), float64(b[i][j])) / 8
+					coefficients[i][j] = conduction(gradient, float64(kappa))
+				}
+			} (j)
+		}
+		waitGroup.Wait()
+
+		// Move intensity between each pixel and its 4 neighbours
+		// The flux across each boundary uses the average coefficient of the two pixels, and nothing flows over the edge of the image
+		changes := make([]float64, imageHeight)
+		waitGroup.Add(imageHeight)
+		for j := 0; j < imageHeight; j++ {
+			go func(j int) {
+				defer waitGroup.Done()
+				for i := 0; i < imageWidth; i++ {
+					centrePixel := float64(current[i][j])
+					flux := float64(0)
+					if i > 0 {
+						flux += 0.5 * (coefficients[i][j] + coefficients[i - 1][j]) * (float64(current[i - 1][j]) - centrePixel)
+					}
+					if i < imageWidth - 1 {
+						flux += 0.5 * (coefficients[i][j] + coefficients[i + 1][j]) * (float64(current[i + 1][j]) - centrePixel)
+					}
+					if j > 0 {
+						flux += 0.5 * (coefficients[i][j] + coefficients[i][j - 1]) * (float64(current[i][j - 1]) - centrePixel)
+					}
+					if j < imageHeight - 1 {
+						flux += 0.5 * (coefficients[i][j] + coefficients[i][j + 1]) * (float64(current[i][j + 1]) - centrePixel)
+					}
+					change := float64(timeStep) * flux
+					next[i][j] = float32(centrePixel + change)
+					changes[j] += change * change
+				}
+			} (j)
+		}
+		waitGroup.Wait()
+		current, next = next, current
+
+		// Report progress
+		if callback != nil {
+			sum := float64(0)
+			for _, change := range changes {
+				sum += change
+			}
+			rms := math.Sqrt(sum / (float64(imageWidth) * float64(imageHeight)))
+
+			// The buffers are reused by the next iteration, so the callback gets its own copy to keep
+			snapshot := make([][]float32, imageWidth)
+			for i := range snapshot {
+				snapshot[i] = make([]float32, imageHeight)
+				copy(snapshot[i], current[i])
+			}
+			if !callback(iteration, snapshot, float32(rms)) {
+				break
+			}
+		}
+	}
+
+	return current, nil
+}
+
+/*
+ * Clamps a coordinate so that it lies within 0 to size-1 (inclusive)
+ */
+func clamp(coordinate int, size int) int {
+	if coordinate < 0 {
+		return 0
+	}
+	if coordinate >= size {
+		return size - 1
+	}
+	return coordinate
+}
\ No newline at end of file
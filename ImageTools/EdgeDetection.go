@@ -0,0 +1,241 @@
+package ImageTools
+
+import (
+	"ImageTools/kernels"
+	"math"
+	"sync"
+)
+
+/*
+ * Detects edges with the Canny edge detector (https://doi.org/10.1109/TPAMI.1986.4767851)
+ * The image is smoothed with a Gaussian of standard deviation sigma, the Sobel gradients are calculated, the gradient magnitude is thinned with non-maximum suppression, and the result is thresholded with hysteresis
+ * The thresholds are on the gradient magnitude, measured in pixel value units per pixel (so a clean step of height 1 has a magnitude of 0.5)
+ * Returns a binary image with edges that are one pixel thick
+ */
+func Canny(image [][]float32, sigma float32, low float32, high float32) [][]float32 {
+
+	// Smooth the image
+	if sigma > 0 {
+		image = GaussianBlur(image, sigma)
+	}
+
+	// Calculate the gradients
+	gradients := Gradient(image, SobelOperator, false)
+
+	// Thin the edges and apply hysteresis
+	return HysteresisThreshold(NonMaximumSuppression(gradients.X, gradients.Y), low, high)
+}
+
+/*
+ * Thins edges by keeping only the pixels whose gradient magnitude is a local maximum along the gradient direction
+ * Takes the x and y gradients and returns the suppressed gradient magnitude (all non-maximum pixels are set to 0)
+ */
+func NonMaximumSuppression(gx [][]float32, gy [][]float32) [][]float32 {
+
+	imageWidth, imageHeight := Dimensions(gx)
+
+	// Calculate the gradient magnitude
+	magnitude := make([][]float32, imageWidth)
+	for i := range magnitude {
+		magnitude[i] = make([]float32, imageHeight)
+		for j := range magnitude[i] {
+			magnitude[i][j] = float32(math.Hypot(float64(gx[i][j]), float64(gy[i][j])))
+		}
+	}
+
+	// Create output image
+	outputImage := make([][]float32, imageWidth)
+	for j := range outputImage {
+		outputImage[j] = make([]float32, imageHeight)
+	}
+
+	// Create wait group
+	var waitGroup sync.WaitGroup
+	waitGroup.Add(imageHeight)
+
+	// Iterate over columns
+	for j := 0; j < imageHeight; j++ {
+
+		// Process each row on its own goroutine
+		go func(j int) {
+			defer waitGroup.Done()
+
+			// Iterate over row
+			for i := 0; i < imageWidth; i++ {
+				currentPixel := magnitude[i][j]
+				if currentPixel == 0 {
+					continue
+				}
+
+				// Round the gradient direction to the nearest 45 degrees, to find which neighbours lie along it
+				angle := math.Atan2(float64(gy[i][j]), float64(gx[i][j]))
+				if angle < 0 {
+					angle += math.Pi
+				}
+				var nI, nJ int
+				switch sector := int(math.Floor(angle / (math.Pi / 4) + 0.5)) % 4; sector {
+				case 0:
+					nI, nJ = 1, 0
+				case 1:
+					nI, nJ = 1, 1
+				case 2:
+					nI, nJ = 0, 1
+				default:
+					nI, nJ = -1, 1
+				}
+
+				// Pixels off the edge of the image count as 0
+				before, after := float32(0), float32(0)
+				if i - nI >= 0 && i - nI < imageWidth && j - nJ >= 0 && j - nJ < imageHeight {
+					before = magnitude[i - nI][j - nJ]
+				}
+				if i + nI >= 0 && i + nI < imageWidth && j + nJ >= 0 && j + nJ < imageHeight {
+					after = magnitude[i + nI][j + nJ]
+				}
+
+				// Keep the pixel if it's a maximum. Ties are broken in one direction only, so plateaus are still thinned to one pixel
+				if currentPixel > before && currentPixel >= after {
+					outputImage[i][j] = currentPixel
+				}
+			}
+		} (j)
+	}
+
+	// Wait for all goroutines to finish
+	waitGroup.Wait()
+
+	return outputImage
+}
+
+/*
+ * Filters an image with a Laplacian of Gaussian (LoG) of standard deviation sigma, using the separable parts from kernels.SepLaplacianOfGaussian
+ * Edges are at the zero crossings of the output, which can be found with ZeroCrossings
+ * A sigma of 0 or less gives no smoothing, so this is the plain 4-neighbour Laplacian
+ */
+func LaplacianOfGaussianFilter(image [][]float32, sigma float32) [][]float32 {
+
+	// The kernel must be at least 3 wide to hold a second derivative
+	size := kernels.GaussianSize(sigma)
+	if size < 3 {
+		size = 3
+	}
+	gaussianHorizontal, gaussianVertical, secondDerivativeHorizontal, secondDerivativeVertical := kernels.SepLaplacianOfGaussian(size, sigma)
+
+	return zeroSumConvolution(image, func(image [][]float32) [][]float32 {
+
+		// LoG = G''(x)G(y) + G(x)G''(y), calculating each half on its own goroutine
+		ch := make(chan [][]float32)
+		go func() { ch <- SepConvolution(image, secondDerivativeHorizontal, gaussianVertical, false) }()
+		go func() { ch <- SepConvolution(image, gaussianHorizontal, secondDerivativeVertical, false) }()
+		a := <- ch
+		b := <- ch
+		sum, _ := AddImage(a, b, false)
+		return sum
+	})
+}
+
+/*
+ * Filters an image with a Difference of Gaussians (DoG), by subtracting a Gaussian blur with standard deviation sigmaB from one with standard deviation sigmaA
+ * With sigmaB about 1.6 times sigmaA this approximates a (negated) Laplacian of Gaussian. Edges are at the zero crossings of the output, which can be found with ZeroCrossings
+ */
+func DifferenceOfGaussiansFilter(image [][]float32, sigmaA float32, sigmaB float32) [][]float32 {
+
+	// Blur the image with both Gaussians on their own goroutines
+	ch1 := make(chan [][]float32)
+	ch2 := make(chan [][]float32)
+	go func() { ch1 <- GaussianBlur(image, sigmaA) }()
+	go func() { ch2 <- GaussianBlur(image, sigmaB) }()
+	a := <- ch1
+	b := <- ch2
+
+	difference, _ := SubtractImage(a, b, false)
+	return difference
+}
+
+/*
+ * Finds the zero crossings of a filter response (such as LaplacianOfGaussianFilter or DifferenceOfGaussiansFilter)
+ * A pixel is marked as an edge if its sign is different to one of its 4 neighbours, the jump between them is bigger than threshold, and it is the closer of the two to zero
+ * Returns a binary image
+ */
+func ZeroCrossings(response [][]float32, threshold float32) [][]float32 {
+
+	imageWidth, imageHeight := Dimensions(response)
+	neighbours := [4][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}}
+
+	// Create output image
+	outputImage := make([][]float32, imageWidth)
+	for j := range outputImage {
+		outputImage[j] = make([]float32, imageHeight)
+	}
+
+	// Create wait group
+	var waitGroup sync.WaitGroup
+	waitGroup.Add(imageHeight)
+
+	// Iterate over columns
+	for j := 0; j < imageHeight; j++ {
+
+		// Process each row on its own goroutine
+		go func(j int) {
+			defer waitGroup.Done()
+
+			// Iterate over row
+			for i := 0; i < imageWidth; i++ {
+				currentPixel := response[i][j]
+				for _, neighbour := range neighbours {
+					nI, nJ := i + neighbour[0], j + neighbour[1]
+					if nI < 0 || nI >= imageWidth || nJ < 0 || nJ >= imageHeight {
+						continue
+					}
+					neighbourPixel := response[nI][nJ]
+
+					// Only one pixel of each crossing is marked, so the edges are thin. Ties go to the positive side
+					if (currentPixel >= 0) == (neighbourPixel >= 0) || float32(math.Abs(float64(currentPixel - neighbourPixel))) <= threshold {
+						continue
+					}
+					currentAbs, neighbourAbs := math.Abs(float64(currentPixel)), math.Abs(float64(neighbourPixel))
+					if currentAbs < neighbourAbs || (currentAbs == neighbourAbs && currentPixel >= 0) {
+						outputImage[i][j] = 1
+						break
+					}
+				}
+			}
+		} (j)
+	}
+
+	// Wait for all goroutines to finish
+	waitGroup.Wait()
+
+	return outputImage
+}
+
+/*
+ * Applies a filter whose kernel sums to zero (such as a Laplacian) to an image, without the zero padding making the border look like an edge
+ * The missing part of the kernel is minus the filtered image of ones, so the padding can be swapped for the centre pixel after the fact
+ */
+func zeroSumConvolution(image [][]float32, filter func(image [][]float32) [][]float32) [][]float32 {
+
+	imageWidth, imageHeight := Dimensions(image)
+
+	// Create an image of ones, to find out how much of the kernel falls off the edge of the image at each pixel
+	ones := make([][]float32, imageWidth)
+	for i := range ones {
+		ones[i] = make([]float32, imageHeight)
+		for j := range ones[i] {
+			ones[i][j] = 1
+		}
+	}
+
+	// Filter the image and the ones on their own goroutines
+	ch1 := make(chan [][]float32)
+	ch2 := make(chan [][]float32)
+	go func() { ch1 <- filter(image) }()
+	go func() { ch2 <- filter(ones) }()
+	response := <- ch1
+	border := <- ch2
+
+	// Swap the padding for the centre pixel
+	correction, _ := MultiplyImage(image, border, false)
+	response, _ = SubtractImage(response, correction, false)
+	return response
+}
\ No newline at end of file
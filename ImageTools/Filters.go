@@ -8,12 +8,13 @@ import (
 
 /*
  * Applies a kernel convolution to an image
+ * The kernel is centred on each pixel (for even sized kernels the centre is rounded down), and pixels off the edge of the image are treated as 0
  */
 func Convolution(image [][]float32, kernel [][]float32, normalise bool) [][]float32 {
 
 	imageWidth, imageHeight := Dimensions(image)
 	kernelWidth, kernelHeight := Dimensions(kernel)
-	halfKernelWidth, halfKernelHeight := int(math.Ceil(0.5 * float64(kernelWidth))), int(math.Ceil(0.5 * float64(kernelHeight)))
+	halfKernelWidth, halfKernelHeight := kernelWidth / 2, kernelHeight / 2
 
 	// Pad image with a border of zeros big enough to prevent the kernel from going over the edge
 	paddedImage := make([][]float32, imageWidth + halfKernelWidth + halfKernelWidth)
@@ -86,6 +87,38 @@ func SepConvolution(image [][]float32, kernelA [][]float32, kernelB [][]float32,
 	return image
 }
 
+/*
+ * Blurs an image with a Gaussian of a given standard deviation
+ * Unlike a plain convolution, the blur is renormalised near the edges of the image, so the border doesn't fade to black
+ * A sigma of 0 or less leaves the image unchanged
+ */
+func GaussianBlur(image [][]float32, sigma float32) [][]float32 {
+
+	imageWidth, imageHeight := Dimensions(image)
+	horizontal, vertical := kernels.SepGaussian(kernels.GaussianSize(sigma), sigma)
+
+	// Create an image of ones, to find out how much of the kernel falls inside the image at each pixel
+	ones := make([][]float32, imageWidth)
+	for i := range ones {
+		ones[i] = make([]float32, imageHeight)
+		for j := range ones[i] {
+			ones[i][j] = 1
+		}
+	}
+
+	// Blur the image and the ones on their own goroutines
+	ch1 := make(chan [][]float32)
+	ch2 := make(chan [][]float32)
+	go func() { ch1 <- SepConvolution(image, horizontal, vertical, false) }()
+	go func() { ch2 <- SepConvolution(ones, horizontal, vertical, false) }()
+	blurred := <- ch1
+	weights := <- ch2
+
+	// Divide out the weights (they can never be zero, because the centre of the kernel is always inside the image)
+	blurred, _ = DivideImage(blurred, weights, false)
+	return blurred
+}
+
 /*
  * Calculates the gradient magnitude at each pixel in an image
  */
@@ -150,4 +183,155 @@ func PixelOrientation(image [][]float32) [][]float32 {
 
 	// Normalise in the range 0-1 (inclusive) and return
 	return Normalise(image)
+}
+
+/*
+ * A separable gradient operator, made up of a smoothing kernel and a derivative kernel
+ * Both parts are stored in their horizontal form. The x gradient uses the horizontal derivative and vertical smoothing, and the y gradient is the other way round
+ */
+type GradientOperator struct {
+	smoothing, derivative [][]float32
+}
+
+var SobelOperator = NewGradientOperator(kernels.SepSobelXPt1, kernels.SepSobelYPt2)
+var ScharrOperator = NewGradientOperator(kernels.SepScharrXPt1, kernels.SepScharrYPt2)
+var PrewittOperator = NewGradientOperator(kernels.SepPrewittXPt1, kernels.SepPrewittYPt2)
+
+/*
+ * Creates a gradient operator from the horizontal parts of its smoothing and derivative kernels
+ * The kernels are rescaled, so that the gradients come out in pixel value units per pixel, whichever operator is used
+ */
+func NewGradientOperator(smoothing [][]float32, derivative [][]float32) GradientOperator {
+
+	// Copy the smoothing kernel and make it sum to 1
+	smoothingCopy := make([][]float32, len(smoothing))
+	for i := range smoothing {
+		smoothingCopy[i] = []float32{smoothing[i][0]}
+	}
+
+	// Copy the derivative kernel and make its response to a ramp that goes up by 1 per pixel equal to 1
+	ramp := float64(0)
+	for i := range derivative {
+		ramp += float64(i - len(derivative) / 2) * float64(derivative[i][0])
+	}
+	derivativeCopy := make([][]float32, len(derivative))
+	for i := range derivative {
+		derivativeCopy[i] = []float32{float32(float64(derivative[i][0]) / ramp)}
+	}
+
+	return GradientOperator{smoothing: kernels.NormaliseKernel(smoothingCopy), derivative: derivativeCopy}
+}
+
+/*
+ * Creates a derivative of Gaussian gradient operator with a given standard deviation
+ * A sigma of 0 or less gives no smoothing, so the gradients are plain central differences
+ */
+func GaussianDerivativeOperator(sigma float32) GradientOperator {
+
+	// The kernels must be at least 3 wide to hold a central difference
+	size := kernels.GaussianSize(sigma)
+	if size < 3 {
+		size = 3
+	}
+	smoothing, _ := kernels.SepGaussian(size, sigma)
+	derivative, _ := kernels.SepGaussianDerivative(size, sigma)
+	return GradientOperator{smoothing: smoothing, derivative: derivative}
+}
+
+/*
+ * The gradients of an image
+ * X and Y are the signed gradients, Magnitude is their length and Direction is atan2(Y, X) in radians, in the range -pi to pi
+ * Note that y points down the image, so a positive direction is clockwise from the x axis
+ */
+type Gradients struct {
+	X, Y, Magnitude, Direction [][]float32
+}
+
+/*
+ * Calculates the signed gradients of an image, along with their magnitude and direction, using the given operator
+ * Unless they are normalised, the gradients are in pixel value units per pixel, so a clean step of height 1 has a magnitude of about 0.5 with the Sobel operator
+ * If the normalise arg is true, X, Y and Magnitude are all divided by the largest magnitude (so the sign and relative scale of X and Y are kept), and Direction is mapped in the range 0-1
+ * Pixels off the edge of the image are treated as if they were the same as the centre pixel, so the border doesn't look like an edge
+ */
+func Gradient(image [][]float32, operator GradientOperator, normalise bool) Gradients {
+
+	imageWidth, imageHeight := Dimensions(image)
+	smoothingVertical, derivativeVertical := kernels.Transpose(operator.smoothing), kernels.Transpose(operator.derivative)
+
+	// Calculate the x and y gradients on their own goroutines
+	// Both kernels sum to zero, so the zero padding can be corrected for
+	ch1 := make(chan [][]float32)
+	ch2 := make(chan [][]float32)
+	go func() {
+		ch1 <- zeroSumConvolution(image, func(image [][]float32) [][]float32 {
+			return SepConvolution(image, operator.derivative, smoothingVertical, false)
+		})
+	}()
+	go func() {
+		ch2 <- zeroSumConvolution(image, func(image [][]float32) [][]float32 {
+			return SepConvolution(image, operator.smoothing, derivativeVertical, false)
+		})
+	}()
+	gradients := Gradients{X: <- ch1, Y: <- ch2}
+
+	// Create output images
+	gradients.Magnitude = make([][]float32, imageWidth)
+	gradients.Direction = make([][]float32, imageWidth)
+	for j := range gradients.Magnitude {
+		gradients.Magnitude[j] = make([]float32, imageHeight)
+		gradients.Direction[j] = make([]float32, imageHeight)
+	}
+
+	// Keep track of the largest magnitude in each row, for normalising
+	rowMax := make([]float64, imageHeight)
+
+	// Create wait group
+	var waitGroup sync.WaitGroup
+	waitGroup.Add(imageHeight)
+
+	// Iterate over columns
+	for j := 0; j < imageHeight; j++ {
+
+		// Process each row on its own goroutine
+		go func(j int) {
+			defer waitGroup.Done()
+
+			// Iterate over row
+			for i := 0; i < imageWidth; i++ {
+				gx, gy := float64(gradients.X[i][j]), float64(gradients.Y[i][j])
+				magnitude := math.Hypot(gx, gy)
+				gradients.Magnitude[i][j] = float32(magnitude)
+				gradients.Direction[i][j] = float32(math.Atan2(gy, gx))
+				if magnitude > rowMax[j] {
+					rowMax[j] = magnitude
+				}
+			}
+		} (j)
+	}
+
+	// Wait for all goroutines to finish
+	waitGroup.Wait()
+
+	if normalise {
+
+		// Find the largest magnitude
+		max := float64(0)
+		for _, m := range rowMax {
+			if m > max {
+				max = m
+			}
+		}
+		if max == 0 {
+			max = 1
+		}
+
+		// Scale everything
+		gradients.X, _ = MultiplyScalar(gradients.X, float32(1 / max), false)
+		gradients.Y, _ = MultiplyScalar(gradients.Y, float32(1 / max), false)
+		gradients.Magnitude, _ = MultiplyScalar(gradients.Magnitude, float32(1 / max), false)
+		gradients.Direction, _ = AddScalar(gradients.Direction, math.Pi, false)
+		gradients.Direction, _ = MultiplyScalar(gradients.Direction, float32(1 / (2 * math.Pi)), false)
+	}
+
+	return gradients
 }
\ No newline at end of file
@@ -0,0 +1,320 @@
+package ImageTools
+
+import (
+	"errors"
+	"math"
+	"sync"
+)
+
+/*
+ * An offset from the centre of a structuring element, with the element's height there
+ */
+type structuringOffset struct {
+	x, y int
+	height float32
+}
+
+/*
+ * Performs grayscale morphological erosion, setting each pixel to the minimum of image(x + b) - heights(b) over the points b of a structuring element
+ * Points where element is non-zero are part of the structuring element, which is centred in the same way as a Convolution kernel
+ * heights gives a non-flat structuring element, and must be the same size as element. If it is nil, the element is flat and this is a min filter
+ * Pixels off the edge of the image are ignored. Flat rectangles use the van Herk/Gil-Werman algorithm, whose speed doesn't depend on the size of the rectangle
+ */
+func GrayscaleErosion(image [][]float32, element [][]float32, heights [][]float32) ([][]float32, error) {
+	offsets, rectangle, err := structuringOffsets(element, heights, false)
+	if err != nil {
+		return nil, err
+	}
+	if rectangle {
+		width, height := Dimensions(element)
+		return rectangleFilter(image, width, height, -(width / 2), -(height / 2), false), nil
+	}
+	return grayscaleMorphology(image, offsets, false), nil
+}
+
+/*
+ * Performs grayscale morphological dilation, setting each pixel to the maximum of image(x - b) + heights(b) over the points b of a structuring element
+ * Points where element is non-zero are part of the structuring element, which is centred in the same way as a Convolution kernel
+ * heights gives a non-flat structuring element, and must be the same size as element. If it is nil, the element is flat and this is a max filter
+ * Pixels off the edge of the image are ignored. Flat rectangles use the van Herk/Gil-Werman algorithm, whose speed doesn't depend on the size of the rectangle
+ */
+func GrayscaleDilation(image [][]float32, element [][]float32, heights [][]float32) ([][]float32, error) {
+	offsets, rectangle, err := structuringOffsets(element, heights, true)
+	if err != nil {
+		return nil, err
+	}
+	if rectangle {
+		width, height := Dimensions(element)
+		return rectangleFilter(image, width, height, -(width - 1 - width / 2), -(height - 1 - height / 2), true), nil
+	}
+	return grayscaleMorphology(image, offsets, true), nil
+}
+
+/*
+ * Performs grayscale morphological opening (erosion followed by dilation), which removes bright details smaller than the structuring element
+ */
+func GrayscaleOpening(image [][]float32, element [][]float32, heights [][]float32) ([][]float32, error) {
+
+	// Erode
+	eroded, err := GrayscaleErosion(image, element, heights)
+	if err != nil {
+		return nil, err
+	}
+
+	// Dilate
+	return GrayscaleDilation(eroded, element, heights)
+}
+
+/*
+ * Performs grayscale morphological closing (dilation followed by erosion), which removes dark details smaller than the structuring element
+ */
+func GrayscaleClosing(image [][]float32, element [][]float32, heights [][]float32) ([][]float32, error) {
+
+	// Dilate
+	dilated, err := GrayscaleDilation(image, element, heights)
+	if err != nil {
+		return nil, err
+	}
+
+	// Erode
+	return GrayscaleErosion(dilated, element, heights)
+}
+
+/*
+ * Sets each pixel to the minimum of the width x height rectangle around it, using the van Herk/Gil-Werman algorithm
+ * This is the same as GrayscaleErosion with a flat rectangular structuring element
+ */
+func MinFilter(image [][]float32, width int, height int) ([][]float32, error) {
+	if width < 1 || height < 1 {
+		return nil, errors.New("Filter size must be at least 1x1")
+	}
+	return rectangleFilter(image, width, height, -(width / 2), -(height / 2), false), nil
+}
+
+/*
+ * Sets each pixel to the maximum of the width x height rectangle around it, using the van Herk/Gil-Werman algorithm
+ * The rectangle is centred in the same way as MinFilter, so for even sizes this is not quite the same as GrayscaleDilation, which reflects the structuring element
+ */
+func MaxFilter(image [][]float32, width int, height int) ([][]float32, error) {
+	if width < 1 || height < 1 {
+		return nil, errors.New("Filter size must be at least 1x1")
+	}
+	return rectangleFilter(image, width, height, -(width / 2), -(height / 2), true), nil
+}
+
+/*
+ * Checks a structuring element and lists the offsets of its points (reflected, for dilation)
+ * Also returns whether it's a flat, solid rectangle, which can use the fast path
+ */
+func structuringOffsets(element [][]float32, heights [][]float32, reflect bool) ([]structuringOffset, bool, error) {
+	if len(element) == 0 || len(element[0]) == 0 {
+		return nil, false, errors.New("Structuring element is empty")
+	}
+	width, height := Dimensions(element)
+	if heights != nil {
+		heightsWidth, heightsHeight := Dimensions(heights)
+		if heightsWidth != width || heightsHeight != height {
+			return nil, false, errors.New("Structuring element heights must be the same size as the structuring element")
+		}
+	}
+
+	var offsets []structuringOffset
+	for i := range element {
+		for j := range element[i] {
+			if element[i][j] == 0 {
+				continue
+			}
+			offset := structuringOffset{x: i - width / 2, y: j - height / 2}
+			if reflect {
+				offset.x, offset.y = -offset.x, -offset.y
+			}
+			if heights != nil {
+				offset.height = heights[i][j]
+			}
+			offsets = append(offsets, offset)
+		}
+	}
+	if len(offsets) == 0 {
+		return nil, false, errors.New("Structuring element has no points")
+	}
+
+	return offsets, heights == nil && len(offsets) == width * height, nil
+}
+
+/*
+ * Performs grayscale erosion or dilation with any structuring element, by checking every one of its points at each pixel
+ */
+func grayscaleMorphology(image [][]float32, offsets []structuringOffset, dilate bool) [][]float32 {
+
+	imageWidth, imageHeight := Dimensions(image)
+
+	// Create output image
+	outputImage := make([][]float32, imageWidth)
+	for j := range outputImage {
+		outputImage[j] = make([]float32, imageHeight)
+	}
+
+	// Create wait group
+	var waitGroup sync.WaitGroup
+	waitGroup.Add(imageHeight)
+
+	// Iterate over columns
+	for j := 0; j < imageHeight; j++ {
+
+		// Process each row on its own goroutine
+		go func(j int) {
+			defer waitGroup.Done()
+
+			// Iterate over row
+			for i := 0; i < imageWidth; i++ {
+
+				// Find the extreme over the structuring element, ignoring points off the edge of the image
+				extreme := float32(math.Inf(1))
+				if dilate {
+					extreme = float32(math.Inf(-1))
+				}
+				for _, offset := range offsets {
+					x, y := i + offset.x, j + offset.y
+					if x < 0 || x >= imageWidth || y < 0 || y >= imageHeight {
+						continue
+					}
+					if dilate {
+						if value := image[x][y] + offset.height; value > extreme {
+							extreme = value
+						}
+					} else {
+						if value := image[x][y] - offset.height; value < extreme {
+							extreme = value
+						}
+					}
+				}
+
+				// If the whole element is off the edge of the image, leave the pixel alone
+				if math.IsInf(float64(extreme), 0) {
+					extreme = image[i][j]
+				}
+				outputImage[i][j] = extreme
+			}
+		} (j)
+	}
+
+	// Wait for all goroutines to finish
+	waitGroup.Wait()
+
+	return outputImage
+}
+
+/*
+ * Finds the minimum or maximum of the width x height rectangle at each pixel, whose top left corner is at (offsetX, offsetY) from the pixel
+ * The rectangle is separable, so a 1D filter is run along the rows and then along the columns
+ */
+func rectangleFilter(image [][]float32, width int, height int, offsetX int, offsetY int, max bool) [][]float32 {
+
+	imageWidth, imageHeight := Dimensions(image)
+
+	// Create output image
+	outputImage := make([][]float32, imageWidth)
+	for j := range outputImage {
+		outputImage[j] = make([]float32, imageHeight)
+	}
+
+	// Create wait group
+	var waitGroup sync.WaitGroup
+	waitGroup.Add(imageHeight)
+
+	// Filter along each row, one goroutine per row
+	for j := 0; j < imageHeight; j++ {
+		go func(j int) {
+			defer waitGroup.Done()
+			row := make([]float32, imageWidth)
+			for i := 0; i < imageWidth; i++ {
+				row[i] = image[i][j]
+			}
+			row = vanHerkGilWerman(row, width, offsetX, max)
+			for i := 0; i < imageWidth; i++ {
+				outputImage[i][j] = row[i]
+			}
+		} (j)
+	}
+	waitGroup.Wait()
+
+	// Then along each column, one goroutine per column
+	waitGroup.Add(imageWidth)
+	for i := 0; i < imageWidth; i++ {
+		go func(i int) {
+			defer waitGroup.Done()
+			outputImage[i] = vanHerkGilWerman(outputImage[i], height, offsetY, max)
+		} (i)
+	}
+	waitGroup.Wait()
+
+	return outputImage
+}
+
+/*
+ * Finds the minimum or maximum of line[i + offset] to line[i + offset + size - 1] for every i, using the van Herk/Gil-Werman algorithm (https://doi.org/10.1016/0167-8655(92)90069-C)
+ * The line is split into blocks of size elements, with running extremes forwards and backwards through each block. Any window spans at most 2 blocks, so its extreme takes one comparison
+ * Elements off the ends of the line are ignored
+ */
+func vanHerkGilWerman(line []float32, size int, offset int, max bool) []float32 {
+
+	// Pick the operation, and the value that padding takes so it never wins
+	pad := float32(math.Inf(1))
+	better := func(a float32, b float32) float32 {
+		if b < a {
+			return b
+		}
+		return a
+	}
+	if max {
+		pad = float32(math.Inf(-1))
+		better = func(a float32, b float32) float32 {
+			if b > a {
+				return b
+			}
+			return a
+		}
+	}
+
+	// Pad the line so every window is inside it
+	length := len(line) + size - 1
+	padded := make([]float32, length)
+	for q := range padded {
+		if n := q + offset; n >= 0 && n < len(line) {
+			padded[q] = line[n]
+		} else {
+			padded[q] = pad
+		}
+	}
+
+	// Running extremes forwards and backwards through each block
+	forwards := make([]float32, length)
+	backwards := make([]float32, length)
+	for q := 0; q < length; q++ {
+		if q % size == 0 {
+			forwards[q] = padded[q]
+		} else {
+			forwards[q] = better(forwards[q - 1], padded[q])
+		}
+	}
+	for q := length - 1; q >= 0; q-- {
+		if q == length - 1 || (q + 1) % size == 0 {
+			backwards[q] = padded[q]
+		} else {
+			backwards[q] = better(backwards[q + 1], padded[q])
+		}
+	}
+
+	// Combine the two blocks each window spans
+	output := make([]float32, len(line))
+	for i := range output {
+		output[i] = better(backwards[i], forwards[i + size - 1])
+
+		// If the whole window is off the end of the line, leave the element alone
+		if output[i] == pad {
+			output[i] = line[i]
+		}
+	}
+
+	return output
+}
\ No newline at end of file
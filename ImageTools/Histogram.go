@@ -0,0 +1,230 @@
+package ImageTools
+
+import (
+	"errors"
+	"sync"
+)
+
+/*
+ * A histogram of pixel values, with equal width bins covering Min to Max
+ * Counts are float64s, so histograms can be clipped, smoothed or given directly as a target
+ */
+type Histogram struct {
+	Counts []float64
+	Min, Max float32
+}
+
+/*
+ * Calculates the histogram of an image, with bins bins covering min to max
+ * Pixels outside the range are counted in the first or last bin
+ * If mask isn't nil, only the pixels where the mask is non-zero are counted. It must be the same size as the image
+ */
+func NewHistogram(image [][]float32, bins int, min float32, max float32, mask [][]float32) (*Histogram, error) {
+
+	// Check the parameters
+	if bins < 1 {
+		return nil, errors.New("There must be at least 1 bin")
+	}
+	if max <= min {
+		return nil, errors.New("Histogram range must not be empty")
+	}
+	imageWidth, imageHeight := Dimensions(image)
+	if mask != nil {
+		maskWidth, maskHeight := Dimensions(mask)
+		if maskWidth != imageWidth || maskHeight != imageHeight {
+			return nil, errors.New("Mask must be the same size as the image")
+		}
+	}
+	histogram := &Histogram{Counts: make([]float64, bins), Min: min, Max: max}
+
+	// Create wait group
+	var waitGroup sync.WaitGroup
+	waitGroup.Add(imageHeight)
+	var mutex sync.Mutex
+
+	// Iterate over columns
+	for j := 0; j < imageHeight; j++ {
+
+		// Process each row on its own goroutine, then add it to the total
+		go func(j int) {
+			defer waitGroup.Done()
+			counts := make([]float64, bins)
+
+			// Iterate over row
+			for i := 0; i < imageWidth; i++ {
+				if mask == nil || mask[i][j] != 0 {
+					counts[histogram.Bin(image[i][j])]++
+				}
+			}
+
+			mutex.Lock()
+			for b := range counts {
+				histogram.Counts[b] += counts[b]
+			}
+			mutex.Unlock()
+		} (j)
+	}
+
+	// Wait for all goroutines to finish
+	waitGroup.Wait()
+
+	return histogram, nil
+}
+
+/*
+ * Returns the number of bins
+ */
+func (histogram *Histogram) Bins() int {
+	return len(histogram.Counts)
+}
+
+/*
+ * Returns the width of each bin
+ */
+func (histogram *Histogram) BinWidth() float32 {
+	return (histogram.Max - histogram.Min) / float32(len(histogram.Counts))
+}
+
+/*
+ * Returns the bin a value falls into. Values outside the range go in the first or last bin
+ */
+func (histogram *Histogram) Bin(value float32) int {
+	bins := len(histogram.Counts)
+	return clamp(int(float64(value - histogram.Min) / float64(histogram.Max - histogram.Min) * float64(bins)), bins)
+}
+
+/*
+ * Returns the value at the centre of a bin
+ */
+func (histogram *Histogram) BinCentre(bin int) float32 {
+	return histogram.Min + (float32(bin) + 0.5) * histogram.BinWidth()
+}
+
+/*
+ * Returns the total count over all the bins
+ */
+func (histogram *Histogram) Total() float64 {
+	total := float64(0)
+	for _, count := range histogram.Counts {
+		total += count
+	}
+	return total
+}
+
+/*
+ * Returns the counts divided by the total, so they sum to 1
+ */
+func (histogram *Histogram) Probabilities() []float64 {
+	probabilities := make([]float64, len(histogram.Counts))
+	total := histogram.Total()
+	if total == 0 {
+		return probabilities
+	}
+	for b, count := range histogram.Counts {
+		probabilities[b] = count / total
+	}
+	return probabilities
+}
+
+/*
+ * Returns the cumulative histogram, where each bin holds the count of itself and every bin below it
+ */
+func (histogram *Histogram) Cumulative() []float64 {
+	cumulative := make([]float64, len(histogram.Counts))
+	accumulator := float64(0)
+	for b, count := range histogram.Counts {
+		accumulator += count
+		cumulative[b] = accumulator
+	}
+	return cumulative
+}
+
+/*
+ * Returns the mean of the histogram, taking each bin's values to be at its centre
+ */
+func (histogram *Histogram) Mean() float32 {
+	total, accumulator := float64(0), float64(0)
+	for b, count := range histogram.Counts {
+		total += count
+		accumulator += count * float64(histogram.BinCentre(b))
+	}
+	if total == 0 {
+		return 0
+	}
+	return float32(accumulator / total)
+}
+
+/*
+ * Returns the fraction of the histogram below a value (the cumulative distribution function)
+ * The values in each bin are taken to be spread evenly across it, so the result is continuous
+ * Use CDFFunction instead to evaluate it for lots of values
+ */
+func (histogram *Histogram) CDF(value float32) float64 {
+	return histogram.CDFFunction()(value)
+}
+
+/*
+ * Returns the cumulative distribution function as a function, which only has to sum the histogram once, so it is fast for mapping whole images
+ */
+func (histogram *Histogram) CDFFunction() func(value float32) float64 {
+	cumulative := histogram.Cumulative()
+	total := histogram.Total()
+	binWidth := float64(histogram.BinWidth())
+
+	return func(value float32) float64 {
+		if total == 0 || value <= histogram.Min {
+			return 0
+		}
+		if value >= histogram.Max {
+			return 1
+		}
+
+		// Add the bins below the value, then the part of its own bin that is below it
+		bin := histogram.Bin(value)
+		below := cumulative[bin] - histogram.Counts[bin]
+		fraction := float64(value - histogram.Min) / binWidth - float64(bin)
+		if fraction < 0 {
+			fraction = 0
+		}
+		if fraction > 1 {
+			fraction = 1
+		}
+		return (below + histogram.Counts[bin] * fraction) / total
+	}
+}
+
+/*
+ * Returns the value that a fraction of the histogram is below (the inverse of CDF, also known as the quantile function)
+ * The values in each bin are taken to be spread evenly across it
+ */
+func (histogram *Histogram) InverseCDF(fraction float64) float32 {
+	total := histogram.Total()
+	if total == 0 || fraction <= 0 {
+
+		// Return the bottom of the first non-empty bin
+		for b, count := range histogram.Counts {
+			if count > 0 {
+				return histogram.Min + float32(b) * histogram.BinWidth()
+			}
+		}
+		return histogram.Min
+	}
+
+	// Find the bin the target falls in, then interpolate within it
+	target := fraction * total
+	accumulator := float64(0)
+	for b, count := range histogram.Counts {
+		if count > 0 && accumulator + count >= target {
+			return histogram.Min + (float32(b) + float32((target - accumulator) / count)) * histogram.BinWidth()
+		}
+		accumulator += count
+	}
+
+	// Return the top of the last non-empty bin
+	for b := len(histogram.Counts) - 1; b >= 0; b-- {
+		if histogram.Counts[b] > 0 {
+			return histogram.Min + float32(b + 1) * histogram.BinWidth()
+		}
+	}
+	return histogram.Max
+}
\ No newline at end of file
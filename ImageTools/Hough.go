@@ -0,0 +1,508 @@
+package ImageTools
+
+import (
+	"ImageTools/kernels"
+	"errors"
+	"math"
+	"math/rand"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+/*
+ * The accumulator of a Hough line transform
+ * Lines are parameterised as x*cos(theta) + y*sin(theta) = rho, with theta in the range 0-pi (radians)
+ * Accumulator is indexed [theta bin][rho bin], so it can be saved or thresholded like any other image
+ * Theta bin t is at t * ThetaResolution and rho bin r is at r * RhoResolution - MaxRho
+ */
+type HoughLineSpace struct {
+	Accumulator [][]float32
+	RhoResolution, ThetaResolution float32
+	MaxRho float32
+}
+
+/*
+ * A straight line found by a Hough transform, x*cos(Theta) + y*sin(Theta) = Rho
+ */
+type Line struct {
+	Rho, Theta, Votes float32
+}
+
+/*
+ * A line segment found by ProbabilisticHoughLines, running from (X1, Y1) to (X2, Y2)
+ */
+type LineSegment struct {
+	X1, Y1, X2, Y2 int
+}
+
+/*
+ * Performs a standard Hough line transform on a binary edge image (such as SingleThreshold(GradientMagnitude(image), t) or Canny)
+ * Every edge pixel (anything above 0.5) votes for all of the lines that pass through it
+ * rhoResolution is in pixels and thetaResolution is in radians
+ */
+func HoughLineTransform(edges [][]float32, rhoResolution float32, thetaResolution float32) (HoughLineSpace, error) {
+
+	imageWidth, imageHeight := Dimensions(edges)
+	thetaBins, rhoBins, maxRho, err := houghBins(imageWidth, imageHeight, rhoResolution, thetaResolution)
+	if err != nil {
+		return HoughLineSpace{}, err
+	}
+
+	// Create accumulator
+	accumulator := make([][]float32, thetaBins)
+	for t := range accumulator {
+		accumulator[t] = make([]float32, rhoBins)
+	}
+
+	// Find every edge pixel
+	points := edgePoints(edges)
+
+	// Create wait group
+	var waitGroup sync.WaitGroup
+	waitGroup.Add(thetaBins)
+
+	// Each goroutine handles one angle, so no two goroutines ever write to the same bin
+	for t := 0; t < thetaBins; t++ {
+		go func(t int) {
+			defer waitGroup.Done()
+			theta := float64(t) * float64(thetaResolution)
+			cos, sin := math.Cos(theta), math.Sin(theta)
+			for _, point := range points {
+				rho := float64(point[0]) * cos + float64(point[1]) * sin
+				accumulator[t][int(math.Floor((rho + maxRho) / float64(rhoResolution) + 0.5))]++
+			}
+		} (t)
+	}
+
+	// Wait for all goroutines to finish
+	waitGroup.Wait()
+
+	return HoughLineSpace{
+		Accumulator:     accumulator,
+		RhoResolution:   rhoResolution,
+		ThetaResolution: thetaResolution,
+		MaxRho:          float32(maxRho),
+	}, nil
+}
+
+/*
+ * Finds the strongest lines in a Hough line space, strongest first
+ * A bin is a peak if it has at least threshold votes and no bin within rhoDistance (pixels) and thetaDistance (radians) of it has more
+ * At most maxLines are returned (0 means no limit)
+ */
+func (space HoughLineSpace) Peaks(threshold float32, rhoDistance float32, thetaDistance float32, maxLines int) []Line {
+
+	thetaBins, rhoBins := Dimensions(space.Accumulator)
+	rhoWindow := int(float64(rhoDistance) / float64(space.RhoResolution))
+	thetaWindow := int(float64(thetaDistance) / float64(space.ThetaResolution))
+
+	// Half of the theta bins either side already covers every angle, and theta only wraps round once
+	if thetaWindow > thetaBins / 2 {
+		thetaWindow = thetaBins / 2
+	}
+
+	// Find the peaks for each angle
+	peaks := make([][]Line, thetaBins)
+
+	// Create wait group
+	var waitGroup sync.WaitGroup
+	waitGroup.Add(thetaBins)
+
+	for t := 0; t < thetaBins; t++ {
+		go func(t int) {
+			defer waitGroup.Done()
+			for r := 0; r < rhoBins; r++ {
+				votes := space.Accumulator[t][r]
+				if votes < threshold || votes == 0 {
+					continue
+				}
+
+				// Check the neighbourhood. Theta wraps round, and a line at theta - pi is the same as the one at theta with rho negated
+				isPeak := true
+				for nT := t - thetaWindow; nT <= t + thetaWindow && isPeak; nT++ {
+					for nR := r - rhoWindow; nR <= r + rhoWindow; nR++ {
+						wrappedT, wrappedR := nT, nR
+						if wrappedT < 0 {
+							wrappedT, wrappedR = wrappedT + thetaBins, rhoBins - 1 - wrappedR
+						} else if wrappedT >= thetaBins {
+							wrappedT, wrappedR = wrappedT - thetaBins, rhoBins - 1 - wrappedR
+						}
+						if (nT == t && nR == r) || wrappedR < 0 || wrappedR >= rhoBins {
+							continue
+						}
+
+						// Ties are broken by position, so a plateau only gives one peak
+						neighbour := space.Accumulator[wrappedT][wrappedR]
+						if neighbour > votes || (neighbour == votes && (nT < t || (nT == t && nR < r))) {
+							isPeak = false
+							break
+						}
+					}
+				}
+				if isPeak {
+					peaks[t] = append(peaks[t], Line{
+						Rho:   float32(r) * space.RhoResolution - space.MaxRho,
+						Theta: float32(t) * space.ThetaResolution,
+						Votes: votes,
+					})
+				}
+			}
+		} (t)
+	}
+
+	// Wait for all goroutines to finish
+	waitGroup.Wait()
+
+	// Rank the peaks, strongest first
+	var lines []Line
+	for _, row := range peaks {
+		lines = append(lines, row...)
+	}
+	sort.SliceStable(lines, func(a int, b int) bool {
+		return lines[a].Votes > lines[b].Votes
+	})
+	if maxLines > 0 && len(lines) > maxLines {
+		lines = lines[:maxLines]
+	}
+
+	return lines
+}
+
+/*
+ * Finds straight line segments in a binary edge image with the progressive probabilistic Hough transform (https://doi.org/10.1006/cviu.1999.0831)
+ * Edge pixels vote one at a time, in a random order. As soon as a line gets threshold votes, the edge image is followed along it to find the segment
+ * Segments shorter than minLineLength are ignored, and gaps of up to maxLineGap pixels are bridged
+ * The pixels of every segment found are removed, so they can't vote for anything else. The random order is seeded, so results are repeatable
+ */
+func ProbabilisticHoughLines(edges [][]float32, rhoResolution float32, thetaResolution float32, threshold int, minLineLength float32, maxLineGap int) ([]LineSegment, error) {
+
+	imageWidth, imageHeight := Dimensions(edges)
+	thetaBins, rhoBins, maxRho, err := houghBins(imageWidth, imageHeight, rhoResolution, thetaResolution)
+	if err != nil {
+		return nil, err
+	}
+
+	// Precompute the sines and cosines
+	cos, sin := make([]float64, thetaBins), make([]float64, thetaBins)
+	for t := range cos {
+		theta := float64(t) * float64(thetaResolution)
+		cos[t], sin[t] = math.Cos(theta), math.Sin(theta)
+	}
+	rhoBin := func(x int, y int, t int) int {
+		return int(math.Floor((float64(x) * cos[t] + float64(y) * sin[t] + maxRho) / float64(rhoResolution) + 0.5))
+	}
+
+	// Keep track of which pixels are still edges and which have voted
+	remaining := make([][]bool, imageWidth)
+	voted := make([][]bool, imageWidth)
+	for i := range remaining {
+		remaining[i] = make([]bool, imageHeight)
+		voted[i] = make([]bool, imageHeight)
+	}
+	points := edgePoints(edges)
+	for _, point := range points {
+		remaining[point[0]][point[1]] = true
+	}
+
+	// Shuffle the edge pixels
+	random := rand.New(rand.NewSource(1))
+	random.Shuffle(len(points), func(a int, b int) {
+		points[a], points[b] = points[b], points[a]
+	})
+
+	// Create accumulator
+	accumulator := make([][]int, thetaBins)
+	for t := range accumulator {
+		accumulator[t] = make([]int, rhoBins)
+	}
+
+	var segments []LineSegment
+	for _, point := range points {
+		x0, y0 := point[0], point[1]
+		if !remaining[x0][y0] {
+			continue
+		}
+
+		// Vote, keeping track of the strongest line through this pixel
+		bestVotes, bestT := 0, 0
+		for t := 0; t < thetaBins; t++ {
+			r := rhoBin(x0, y0, t)
+			accumulator[t][r]++
+			if accumulator[t][r] > bestVotes {
+				bestVotes, bestT = accumulator[t][r], t
+			}
+		}
+		voted[x0][y0] = true
+		if bestVotes < threshold {
+			continue
+		}
+
+		// Walk along the line in both directions from this pixel, bridging small gaps
+		// Step one pixel at a time along whichever axis the line is closer to
+		dx, dy := -sin[bestT], cos[bestT]
+		step := math.Max(math.Abs(dx), math.Abs(dy))
+		dx, dy = dx / step, dy / step
+		var ends [2][2]int
+		for direction := 0; direction < 2; direction++ {
+			sign := float64(1 - 2 * direction)
+			ends[direction] = [2]int{x0, y0}
+			gap := 0
+			for k := 1; ; k++ {
+				x := int(math.Floor(float64(x0) + sign * float64(k) * dx + 0.5))
+				y := int(math.Floor(float64(y0) + sign * float64(k) * dy + 0.5))
+				if x < 0 || x >= imageWidth || y < 0 || y >= imageHeight {
+					break
+				}
+				if remaining[x][y] {
+					gap = 0
+					ends[direction] = [2]int{x, y}
+				} else {
+					gap++
+					if gap > maxLineGap {
+						break
+					}
+				}
+			}
+		}
+
+		// Ignore short segments
+		length := math.Hypot(float64(ends[0][0] - ends[1][0]), float64(ends[0][1] - ends[1][1]))
+		if length < float64(minLineLength) {
+			continue
+		}
+
+		// Remove the segment's pixels from the edge image, taking back any votes they have already made
+		steps := int(math.Max(math.Abs(float64(ends[0][0] - ends[1][0])), math.Abs(float64(ends[0][1] - ends[1][1]))))
+		for k := 0; k <= steps; k++ {
+			fraction := float64(0)
+			if steps > 0 {
+				fraction = float64(k) / float64(steps)
+			}
+			x := int(math.Floor(float64(ends[1][0]) + fraction * float64(ends[0][0] - ends[1][0]) + 0.5))
+			y := int(math.Floor(float64(ends[1][1]) + fraction * float64(ends[0][1] - ends[1][1]) + 0.5))
+			if !remaining[x][y] {
+				continue
+			}
+			if voted[x][y] {
+				for t := 0; t < thetaBins; t++ {
+					accumulator[t][rhoBin(x, y, t)]--
+				}
+				voted[x][y] = false
+			}
+			remaining[x][y] = false
+		}
+
+		segments = append(segments, LineSegment{X1: ends[1][0], Y1: ends[1][1], X2: ends[0][0], Y2: ends[0][1]})
+	}
+
+	return segments, nil
+}
+
+/*
+ * A circle found by HoughCircles, centred on (X, Y)
+ * Score is the fraction of the circumference that is covered by edge pixels
+ */
+type Circle struct {
+	X, Y, Radius, Score float32
+}
+
+/*
+ * Finds circles with the gradient Hough transform (https://doi.org/10.1016/0262-8856(90)90059-E)
+ * Each edge pixel (anything above 0.5 in edges) votes for the centres that lie along its gradient direction, between minRadius and maxRadius pixels away, in both directions
+ * The gradients should come from the same smoothed image as the edges, for example Gradient(GaussianBlur(image, sigma), SobelOperator, false) and Canny(image, sigma, ...)
+ * resolution is the size of an accumulator bin in pixels, centres with fewer than threshold votes (after the accumulator has been lightly smoothed) are ignored, and centres closer than minDistance to a stronger one are thrown away
+ * The radius of each circle is then the distance from its centre that the most edge pixels lie at. Circles are returned strongest first
+ */
+func HoughCircles(edges [][]float32, gradients Gradients, minRadius int, maxRadius int, resolution float32, minDistance float32, threshold float32) ([]Circle, error) {
+
+	// Check the parameters
+	imageWidth, imageHeight := Dimensions(edges)
+	gradientWidth, gradientHeight := Dimensions(gradients.X)
+	if imageWidth != gradientWidth || imageHeight != gradientHeight {
+		return nil, errors.New("Size mismatch")
+	}
+	if minRadius < 1 || maxRadius < minRadius {
+		return nil, errors.New("Invalid radius range")
+	}
+	if resolution <= 0 {
+		return nil, errors.New("Resolution must be greater than 0")
+	}
+
+	// Find every edge pixel with a gradient
+	var points [][2]int
+	for _, point := range edgePoints(edges) {
+		if gradients.X[point[0]][point[1]] != 0 || gradients.Y[point[0]][point[1]] != 0 {
+			points = append(points, point)
+		}
+	}
+
+	// Split the edge pixels between a goroutine per CPU, each with its own accumulator
+	accumulatorWidth := int(math.Ceil(float64(imageWidth) / float64(resolution))) + 1
+	accumulatorHeight := int(math.Ceil(float64(imageHeight) / float64(resolution))) + 1
+	workers := runtime.NumCPU()
+	accumulators := make([][][]float32, workers)
+	var waitGroup sync.WaitGroup
+	waitGroup.Add(workers)
+	for worker := 0; worker < workers; worker++ {
+		go func(worker int) {
+			defer waitGroup.Done()
+			accumulator := make([][]float32, accumulatorWidth)
+			for i := range accumulator {
+				accumulator[i] = make([]float32, accumulatorHeight)
+			}
+			for n := worker; n < len(points); n += workers {
+				x, y := points[n][0], points[n][1]
+				gx, gy := float64(gradients.X[x][y]), float64(gradients.Y[x][y])
+				magnitude := math.Hypot(gx, gy)
+				dx, dy := gx / magnitude, gy / magnitude
+
+				// Vote along the gradient in both directions, since a circle can be brighter or darker than its background
+				for _, sign := range [2]float64{1, -1} {
+					for r := minRadius; r <= maxRadius; r++ {
+						cx := int(math.Floor((float64(x) + sign * float64(r) * dx) / float64(resolution) + 0.5))
+						cy := int(math.Floor((float64(y) + sign * float64(r) * dy) / float64(resolution) + 0.5))
+						if cx < 0 || cy < 0 || cx >= accumulatorWidth || cy >= accumulatorHeight {
+							break
+						}
+						accumulator[cx][cy]++
+					}
+				}
+			}
+			accumulators[worker] = accumulator
+		} (worker)
+	}
+	waitGroup.Wait()
+
+	// Sum the accumulators
+	accumulator := accumulators[0]
+	for _, other := range accumulators[1:] {
+		accumulator, _ = AddImage(accumulator, other, false)
+	}
+
+	// The gradient directions of a pixelated circle are only roughly right, so smooth the accumulator to pull the votes together
+	// The kernel sums to 1 and isn't renormalised at the edges (unlike GaussianBlur), so each vote is only spread out and the threshold is still in votes
+	horizontal, vertical := kernels.SepGaussian(kernels.GaussianSize(1), 1)
+	accumulator = SepConvolution(accumulator, horizontal, vertical, false)
+
+	// Find the candidate centres, which are the local maxima of the accumulator, strongest first
+	minimumVotes := threshold
+	if minimumVotes <= 0 {
+		minimumVotes = math.SmallestNonzeroFloat32
+	}
+	candidates := localMaxima(accumulator, minimumVotes)
+	var circles []Circle
+	minDistanceSquared := float64(minDistance) * float64(minDistance)
+	radiusCounts := make([]float64, maxRadius + 2)
+	for _, candidate := range candidates {
+
+		// Convert the bin back into pixels
+		cx := float64(candidate.X) * float64(resolution)
+		cy := float64(candidate.Y) * float64(resolution)
+
+		// Skip centres that are too close to a stronger one
+		tooClose := false
+		for _, circle := range circles {
+			dx, dy := cx - float64(circle.X), cy - float64(circle.Y)
+			if dx * dx + dy * dy < minDistanceSquared {
+				tooClose = true
+				break
+			}
+		}
+		if tooClose {
+			continue
+		}
+
+		// Count the edge pixels at each distance from the centre
+		for r := range radiusCounts {
+			radiusCounts[r] = 0
+		}
+		for _, point := range points {
+			distance := math.Hypot(float64(point[0]) - cx, float64(point[1]) - cy)
+			r := int(distance + 0.5)
+			if r >= minRadius && r <= maxRadius {
+				radiusCounts[r]++
+			}
+		}
+
+		// Pick the radius with the best coverage, allowing for the circle being up to a pixel thick
+		bestRadius, bestScore := 0, float64(0)
+		for r := minRadius; r <= maxRadius; r++ {
+			count := radiusCounts[r] + 0.5 * (radiusCounts[r - 1] + radiusCounts[r + 1])
+			score := count / (2 * math.Pi * float64(r))
+			if score > bestScore {
+				bestRadius, bestScore = r, score
+			}
+		}
+		if bestRadius == 0 {
+			continue
+		}
+		if bestScore > 1 {
+			bestScore = 1
+		}
+
+		circles = append(circles, Circle{X: float32(cx), Y: float32(cy), Radius: float32(bestRadius), Score: float32(bestScore)})
+	}
+
+	return circles, nil
+}
+
+/*
+ * Works out the number of theta and rho bins needed for a Hough line transform of an image, and the largest rho that can be stored
+ * The rho bins are laid out symmetrically, so that rho = 0 falls exactly in the centre of a bin
+ */
+func houghBins(imageWidth int, imageHeight int, rhoResolution float32, thetaResolution float32) (int, int, float64, error) {
+
+	// Check the parameters
+	if rhoResolution <= 0 {
+		return 0, 0, 0, errors.New("Rho resolution must be greater than 0")
+	}
+	if thetaResolution <= 0 || thetaResolution > math.Pi {
+		return 0, 0, 0, errors.New("Theta resolution must be greater than 0 and no more than pi")
+	}
+
+	thetaBins := int(math.Round(math.Pi / float64(thetaResolution)))
+	rhoOffset := int(math.Ceil(math.Hypot(float64(imageWidth), float64(imageHeight)) / float64(rhoResolution)))
+	return thetaBins, 2 * rhoOffset + 1, float64(rhoOffset) * float64(rhoResolution), nil
+}
+
+/*
+ * Returns the coordinates of every pixel above 0.5 in a binary image
+ */
+func edgePoints(edges [][]float32) [][2]int {
+
+	imageWidth, imageHeight := Dimensions(edges)
+
+	// Find the edge pixels in each row
+	rows := make([][][2]int, imageHeight)
+
+	// Create wait group
+	var waitGroup sync.WaitGroup
+	waitGroup.Add(imageHeight)
+
+	// Iterate over columns
+	for j := 0; j < imageHeight; j++ {
+
+		// Process each row on its own goroutine
+		go func(j int) {
+			defer waitGroup.Done()
+
+			// Iterate over row
+			for i := 0; i < imageWidth; i++ {
+				if edges[i][j] > 0.5 {
+					rows[j] = append(rows[j], [2]int{i, j})
+				}
+			}
+		} (j)
+	}
+
+	// Wait for all goroutines to finish
+	waitGroup.Wait()
+
+	// Join the rows together
+	var points [][2]int
+	for _, row := range rows {
+		points = append(points, row...)
+	}
+	return points
+}
\ No newline at end of file
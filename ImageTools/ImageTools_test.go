@@ -3,6 +3,9 @@ package ImageTools
 import (
 	"ImageTools/kernels"
 	"fmt"
+	"math"
+	"math/cmplx"
+	"math/rand"
 	"testing"
 )
 
@@ -256,4 +259,2143 @@ func TestPixelOrientation(t *testing.T) {
 	if err != nil {
 		t.Fail()
 	}
+}
+
+/*
+ * Creates a synthetic image with a vertical step edge down the middle, plus some Gaussian noise
+ */
+func noisyStepImage(width int, height int, noise float64) ([][]float32, [][]float32) {
+	random := rand.New(rand.NewSource(1))
+	clean := make([][]float32, width)
+	noisy := make([][]float32, width)
+	for i := range clean {
+		clean[i] = make([]float32, height)
+		noisy[i] = make([]float32, height)
+		for j := range clean[i] {
+			if i >= width / 2 {
+				clean[i][j] = 0.8
+			} else {
+				clean[i][j] = 0.2
+			}
+			noisy[i][j] = clean[i][j] + float32(random.NormFloat64() * noise)
+		}
+	}
+	return clean, noisy
+}
+
+func TestBilateralFilter(t *testing.T) {
+	clean, noisy := noisyStepImage(128, 128, 0.05)
+
+	exact, err := BilateralFilter(noisy, 3, 0.15)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fast, err := FastBilateralFilter(noisy, 3, 0.15)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gaussian := Convolution(noisy, kernels.Gaussian(7, 3), false)
+
+	// Both versions should remove noise, and should beat a Gaussian blur because they don't smear the edge
+	noisyRMSE, _, _ := SquareError(clean, noisy)
+	exactRMSE, _, _ := SquareError(clean, exact)
+	fastRMSE, _, _ := SquareError(clean, fast)
+	gaussianRMSE, _, _ := SquareError(clean, gaussian)
+	fmt.Println("RMSE noisy:", noisyRMSE, "exact:", exactRMSE, "fast:", fastRMSE, "gaussian:", gaussianRMSE)
+	if exactRMSE >= noisyRMSE || fastRMSE >= noisyRMSE {
+		t.Fail()
+	}
+	if exactRMSE >= gaussianRMSE || fastRMSE >= gaussianRMSE {
+		t.Fail()
+	}
+
+	// The edge should still be sharp
+	if exact[63][64] > 0.4 || exact[64][64] < 0.6 || fast[63][64] > 0.4 || fast[64][64] < 0.6 {
+		fmt.Println("Edge:", exact[63][64], exact[64][64], fast[63][64], fast[64][64])
+		t.Fail()
+	}
+
+	img, err := LoadImage("test-images/00-original.jpg")
+	if err != nil {
+		t.Fatal()
+	}
+
+	got, err := FastBilateralFilter(img, 16, 0.1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = SaveImage("test-images/TestBilateralFilter__00-fast-bilateral.jpg", got)
+	if err != nil {
+		t.Fail()
+	}
+
+	// Sigmas must be positive
+	for _, sigmas := range [][2]float32{{0, 0.1}, {3, 0}, {-1, 0.1}} {
+		if _, err := BilateralFilter(noisy, sigmas[0], sigmas[1]); err == nil {
+			t.Error("Expected an error for sigmas", sigmas)
+		}
+		if _, err := FastBilateralFilter(noisy, sigmas[0], sigmas[1]); err == nil {
+			t.Error("Expected an error for sigmas", sigmas)
+		}
+	}
+
+	// Small spatial sigmas use the exact filter, and tiny range sigmas still give a grid of a sensible size
+	small, _ := FastBilateralFilter(noisy, 0.5, 0.15)
+	smallExact, _ := BilateralFilter(noisy, 0.5, 0.15)
+	if difference, _, _ := SquareError(small, smallExact); difference != 0 {
+		t.Error("Small spatial sigma differs from the exact filter by", difference)
+	}
+	fine, err := FastBilateralFilter(noisy, 3, 1e-6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fineRMSE, _, _ := SquareError(clean, fine); math.IsNaN(float64(fineRMSE)) || fineRMSE > noisyRMSE {
+		t.Error("Tiny range sigma gave RMSE", fineRMSE)
+	}
+}
+
+/*
+ * Creates a synthetic image with a smooth sinusoidal texture and a step edge, plus some Gaussian noise
+ */
+func noisyTexturedImage(width int, height int, noise float64) ([][]float32, [][]float32) {
+	clean, noisy := noisyStepImage(width, height, 0)
+	random := rand.New(rand.NewSource(2))
+	for i := range clean {
+		for j := range clean[i] {
+			clean[i][j] += float32(0.1 * math.Sin(float64(i) / 6) * math.Cos(float64(j) / 9))
+			noisy[i][j] = clean[i][j] + float32(random.NormFloat64() * noise)
+		}
+	}
+	return clean, noisy
+}
+
+func TestNonLocalMeans(t *testing.T) {
+	clean, noisy := noisyTexturedImage(96, 96, 0.1)
+
+	slow, err := NonLocalMeans(noisy, 2, 5, 0.1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fast, err := FastNonLocalMeans(noisy, 2, 5, 0.1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gaussian := Convolution(noisy, kernels.Gaussian(5, 2), false)
+
+	noisyRMSE, _, _ := SquareError(clean, noisy)
+	slowRMSE, _, _ := SquareError(clean, slow)
+	fastRMSE, _, _ := SquareError(clean, fast)
+	gaussianRMSE, _, _ := SquareError(clean, gaussian)
+	fmt.Println("RMSE noisy:", noisyRMSE, "brute force:", slowRMSE, "integral image:", fastRMSE, "gaussian:", gaussianRMSE)
+
+	// Non-local means should beat both the noisy input and a Gaussian blur
+	if slowRMSE >= noisyRMSE || slowRMSE >= gaussianRMSE {
+		t.Fail()
+	}
+
+	// Both versions compute exactly the same thing, so they should only differ by rounding error
+	differenceRMSE, _, _ := SquareError(slow, fast)
+	if differenceRMSE > 1e-4 {
+		fmt.Println("Difference between brute force and integral image:", differenceRMSE)
+		t.Fail()
+	}
+
+	img, err := LoadImage("test-images/00-original.jpg")
+	if err != nil {
+		t.Fatal()
+	}
+
+	got, err := FastNonLocalMeans(SubImage(img, 1000, 1000, 512, 512), 3, 7, 0.05)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = SaveImage("test-images/TestNonLocalMeans__00-non-local-means.jpg", got)
+	if err != nil {
+		t.Fail()
+	}
+
+	// h must be positive
+	for _, h := range []float32{0, -0.1} {
+		if _, err := NonLocalMeans(noisy, 2, 5, h); err == nil {
+			t.Error("Expected an error for h", h)
+		}
+		if _, err := FastNonLocalMeans(noisy, 2, 5, h); err == nil {
+			t.Error("Expected an error for h", h)
+		}
+	}
+
+	// So must the radii
+	for _, radii := range [][2]int{{-1, 5}, {2, -1}} {
+		if _, err := NonLocalMeans(noisy, radii[0], radii[1], 0.1); err == nil {
+			t.Error("Expected an error for radii", radii)
+		}
+		if _, err := FastNonLocalMeans(noisy, radii[0], radii[1], 0.1); err == nil {
+			t.Error("Expected an error for radii", radii)
+		}
+	}
+}
+
+func BenchmarkNonLocalMeans(b *testing.B) {
+	_, noisy := noisyTexturedImage(256, 256, 0.1)
+	b.Run("brute-force", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			NonLocalMeans(noisy, 3, 7, 0.1)
+		}
+	})
+	b.Run("integral-image", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			FastNonLocalMeans(noisy, 3, 7, 0.1)
+		}
+	})
+}
+
+func TestAnisotropicDiffusion(t *testing.T) {
+	clean, noisy := noisyStepImage(128, 128, 0.05)
+	noisyRMSE, _, _ := SquareError(clean, noisy)
+
+	for name, conduction := range map[string]ConductionFunction{"exponential": ExponentialConduction, "quadratic": QuadraticConduction} {
+
+		// The amount each iteration changes the image should die away as it converges
+		var changes []float32
+		got, err := AnisotropicDiffusion(noisy, 30, 0.1, 0.2, conduction, func(iteration int, image [][]float32, change float32) bool {
+			changes = append(changes, change)
+			return true
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(changes) != 30 || changes[29] >= changes[0] {
+			fmt.Println(name, "changes:", changes)
+			t.Fail()
+		}
+
+		// Noise should be removed without smearing the edge
+		rmse, _, _ := SquareError(clean, got)
+		fmt.Println(name, "RMSE noisy:", noisyRMSE, "diffused:", rmse)
+		if rmse >= noisyRMSE * 0.75 {
+			t.Fail()
+		}
+		if got[63][64] > 0.4 || got[64][64] < 0.6 {
+			fmt.Println(name, "edge:", got[63][64], got[64][64])
+			t.Fail()
+		}
+	}
+
+	// Returning false from the callback should stop early, and the images it's given should be safe to keep
+	var kept [][][]float32
+	got, err := AnisotropicDiffusion(noisy, 30, 0.1, 0.2, ExponentialConduction, func(iteration int, image [][]float32, change float32) bool {
+		kept = append(kept, image)
+		return iteration < 5
+	})
+	if err != nil || len(kept) != 5 {
+		t.Fatal()
+	}
+	if _, _, sse := SquareError(kept[0], kept[2]); sse == 0 {
+		fmt.Println("Kept images were overwritten")
+		t.Fail()
+	}
+	if _, _, sse := SquareError(kept[4], got); sse != 0 {
+		t.Fail()
+	}
+
+	// Unstable time steps should be rejected
+	_, err = AnisotropicDiffusion(noisy, 1, 0.1, 0.5, ExponentialConduction, nil)
+	if err == nil {
+		t.Fail()
+	}
+}
+
+func TestSharpening(t *testing.T) {
+	clean, _ := noisyStepImage(64, 64, 0)
+	blurred := GaussianBlur(clean, 2)
+
+	// A flat image should be left exactly as it is, even on the border
+	flat, _ := AddScalar(make2DSlice(32, 32), 0.5, false)
+	_, _, sse := SquareError(flat, UnsharpMask(flat, 2, 1, 0))
+	_, _, laplacianSSE := SquareError(flat, LaplacianSharpen(flat, 0.1))
+	if sse > 1e-6 || laplacianSSE > 1e-6 {
+		fmt.Println("Flat image changed:", sse, laplacianSSE)
+		t.Fail()
+	}
+
+	for name, sharpened := range map[string][][]float32{"unsharp": UnsharpMask(blurred, 2, 1.5, 0), "laplacian": LaplacianSharpen(blurred, 0.05)} {
+
+		// Sharpening should make the edge steeper
+		if sharpened[33][32] - sharpened[30][32] <= blurred[33][32] - blurred[30][32] {
+			fmt.Println(name, "edge not steeper")
+			t.Fail()
+		}
+
+		// Output should be clamped, not normalised
+		min, max := MinMax(sharpened)
+		if min < 0 || max > 1 {
+			fmt.Println(name, "not clamped:", min, max)
+			t.Fail()
+		}
+		if sharpened[5][32] != blurred[5][32] {
+			fmt.Println(name, "flat region changed:", sharpened[5][32], blurred[5][32])
+			t.Fail()
+		}
+	}
+
+	// A sigma of 0 shouldn't blur at all, so there's no detail to add back
+	if _, _, sse := SquareError(blurred, GaussianBlur(blurred, 0)); sse != 0 {
+		fmt.Println("Gaussian blur with sigma 0 changed the image:", sse)
+		t.Fail()
+	}
+	if _, _, sse := SquareError(blurred, UnsharpMask(blurred, 0, 1.5, 0)); sse != 0 {
+		fmt.Println("Unsharp mask with radius 0 changed the image:", sse)
+		t.Fail()
+	}
+
+	// Detail below the threshold should be ignored
+	_, noisy := noisyStepImage(64, 64, 0.01)
+	thresholded := UnsharpMask(noisy, 1, 2, 0.1)
+	if thresholded[10][10] != noisy[10][10] {
+		t.Fail()
+	}
+
+	img, err := LoadImage("test-images/00-original.jpg")
+	if err != nil {
+		t.Fatal()
+	}
+
+	got := UnsharpMask(img, 3, 1, 0.02)
+	err = SaveImage("test-images/TestSharpening__00-unsharp-mask.jpg", got)
+	if err != nil {
+		t.Fail()
+	}
+
+	got = LaplacianSharpen(img, 0.1)
+	err = SaveImage("test-images/TestSharpening__01-laplacian.jpg", got)
+	if err != nil {
+		t.Fail()
+	}
+}
+
+/*
+ * Creates an empty image
+ */
+func make2DSlice(width int, height int) [][]float32 {
+	slice := make([][]float32, width)
+	for i := range slice {
+		slice[i] = make([]float32, height)
+	}
+	return slice
+}
+
+func TestIntegralImage(t *testing.T) {
+	_, img := noisyTexturedImage(40, 30, 0.1)
+	integral := NewSquaredIntegralImage(img)
+
+	// Rectangle queries should match brute force sums, including rectangles that go off the edge
+	for _, rect := range [][4]int{{0, 0, 40, 30}, {5, 7, 9, 4}, {-3, -2, 6, 5}, {35, 25, 10, 10}} {
+		sum, count := float64(0), 0
+		for i := rect[0]; i < rect[0] + rect[2]; i++ {
+			for j := rect[1]; j < rect[1] + rect[3]; j++ {
+				if i >= 0 && i < 40 && j >= 0 && j < 30 {
+					sum += float64(img[i][j])
+					count++
+				}
+			}
+		}
+		if math.Abs(integral.Sum(rect[0], rect[1], rect[2], rect[3]) - sum) > 1e-4 || integral.Count(rect[0], rect[1], rect[2], rect[3]) != count {
+			fmt.Println("Sum mismatch:", rect, integral.Sum(rect[0], rect[1], rect[2], rect[3]), sum)
+			t.Fail()
+		}
+	}
+
+	// The whole image statistics should match MeanStd
+	mean, std := integral.MeanStd(0, 0, 40, 30)
+	expectedMean, expectedStd := meanStd(img)
+	if math.Abs(mean - expectedMean) > 1e-6 || math.Abs(std - expectedStd) > 1e-4 {
+		fmt.Println("MeanStd mismatch:", mean, expectedMean, std, expectedStd)
+		t.Fail()
+	}
+
+	// The box filter should match a convolution with a kernel of ones
+	for _, size := range []int{3, 4, 7} {
+		_, mae, _ := AbsoluteError(BoxFilter(img, size), Convolution(img, kernels.BinaryErosionDilationStructuringElement(size), false))
+		if mae > 1e-5 {
+			fmt.Println("Box filter mismatch:", size, mae)
+			t.Fail()
+		}
+	}
+
+	// The mean and local std filters should be flat on a flat image
+	flat, _ := AddScalar(make2DSlice(20, 20), 0.3, false)
+	mean32, std32 := MeanStd(MeanFilter(flat, 5))
+	_, localStdMax := MinMax(LocalStd(flat, 5))
+	if math.Abs(float64(mean32) - 0.3) > 1e-6 || std32 > 1e-6 || localStdMax > 1e-3 {
+		fmt.Println("Flat image:", mean32, std32, localStdMax)
+		t.Fail()
+	}
+
+	img, err := LoadImage("test-images/00-original.jpg")
+	if err != nil {
+		t.Fatal()
+	}
+
+	got := MeanFilter(img, 31)
+	err = SaveImage("test-images/TestIntegralImage__00-mean-filter.jpg", got)
+	if err != nil {
+		t.Fail()
+	}
+
+	got = Normalise(LocalStd(img, 15))
+	err = SaveImage("test-images/TestIntegralImage__01-local-std.jpg", got)
+	if err != nil {
+		t.Fail()
+	}
+}
+
+func TestHysteresisThreshold(t *testing.T) {
+	image := make2DSlice(7, 3)
+
+	// A strong pixel, joined to a chain of weak pixels (including a diagonal step)
+	image[0][1], image[1][1], image[2][0] = 0.9, 0.5, 0.5
+
+	// An isolated chain of weak pixels, which should be dropped
+	image[5][1], image[6][1] = 0.5, 0.5
+
+	got := HysteresisThreshold(image, 0.8, 0.4)
+	want := make2DSlice(7, 3)
+	want[0][1], want[1][1], want[2][0] = 1, 1, 1
+	_, _, sae := AbsoluteError(got, want)
+	if sae != 0 {
+		fmt.Println("Hysteresis:", got)
+		t.Fail()
+	}
+
+	// The order of the thresholds shouldn't matter
+	_, _, sae = AbsoluteError(HysteresisThreshold(image, 0.4, 0.8), want)
+	if sae != 0 {
+		t.Fail()
+	}
+
+	// With a lower threshold of 0, the zero pixels aren't weak, so they don't join up with the strong pixel
+	_, _, sae = AbsoluteError(HysteresisThreshold(image, 0.8, 0), want)
+	if sae != 0 {
+		fmt.Println("Hysteresis with a lower threshold of 0:", HysteresisThreshold(image, 0.8, 0))
+		t.Fail()
+	}
+}
+
+func TestCanny(t *testing.T) {
+
+	// A bright square on a dark background
+	square := make2DSlice(64, 64)
+	for i := 16; i < 48; i++ {
+		for j := 16; j < 48; j++ {
+			square[i][j] = 1
+		}
+	}
+
+	edges := Canny(square, 1.5, 0.05, 0.15)
+
+	// Every row crossing the left and right sides should cross exactly one edge pixel on each side, near the boundary
+	for j := 22; j < 42; j++ {
+		left, right := 0, 0
+		for i := 0; i < 32; i++ {
+			if edges[i][j] == 1 {
+				left++
+				if i < 14 || i > 17 {
+					fmt.Println("Edge in the wrong place:", i, j)
+					t.Fail()
+				}
+			}
+			if edges[i + 32][j] == 1 {
+				right++
+			}
+		}
+		if left != 1 || right != 1 {
+			fmt.Println("Row", j, "has", left, "and", right, "edge pixels")
+			t.Fail()
+		}
+	}
+
+	// There should be no edges on the border of the image or in the flat regions
+	if edges[0][0] != 0 || edges[32][32] != 0 || edges[5][32] != 0 {
+		t.Fail()
+	}
+
+	// A low threshold of 0 should still give thin edges, rather than filling in the pixels thinned away by non-maximum suppression
+	step := make2DSlice(16, 16)
+	for i := 8; i < 16; i++ {
+		for j := range step[i] {
+			step[i][j] = 1
+		}
+	}
+	stepEdges := Canny(step, 1, 0, 0.1)
+	for j := range stepEdges[0] {
+		count := 0
+		for i := range stepEdges {
+			count += int(stepEdges[i][j])
+		}
+		if count != 1 {
+			fmt.Println("Row", j, "of the step has", count, "edge pixels with a low threshold of 0")
+			t.Fail()
+			break
+		}
+	}
+
+	img, err := LoadImage("test-images/00-original.jpg")
+	if err != nil {
+		t.Fatal()
+	}
+
+	got := Canny(img, 2, 0.01, 0.03)
+	err = SaveImage("test-images/TestCanny__00-canny.jpg", got)
+	if err != nil {
+		t.Fail()
+	}
+}
+
+func TestEdgeKernels(t *testing.T) {
+	_, img := noisyTexturedImage(48, 40, 0.05)
+
+	// The separable kernels should give the same result as the full ones
+	for name, pair := range map[string][3][][]float32{
+		"scharr x":  {kernels.ScharrX, kernels.SepScharrXPt1, kernels.SepScharrXPt2},
+		"scharr y":  {kernels.ScharrY, kernels.SepScharrYPt1, kernels.SepScharrYPt2},
+		"prewitt x": {kernels.PrewittX, kernels.SepPrewittXPt1, kernels.SepPrewittXPt2},
+		"prewitt y": {kernels.PrewittY, kernels.SepPrewittYPt1, kernels.SepPrewittYPt2},
+	} {
+		_, mae, _ := AbsoluteError(Convolution(img, pair[0], false), SepConvolution(img, pair[1], pair[2], false))
+		if mae > 1e-5 {
+			fmt.Println(name, "MAE:", mae)
+			t.Fail()
+		}
+	}
+
+	// Each Kirsch kernel should be the previous one rotated, so the south kernel is the north one flipped
+	compass := kernels.KirschCompass()
+	if len(compass) != 8 || compass[0][0][0] != 5 || compass[0][0][2] != -3 || compass[4][0][2] != 5 || compass[4][0][0] != -3 || compass[2][2][0] != 5 {
+		fmt.Println("Kirsch:", compass)
+		t.Fail()
+	}
+
+	// Each kernel should respond most strongly to a ramp that gets brighter in its direction
+	directions := [8][2]float32{{0, -1}, {1, -1}, {1, 0}, {1, 1}, {0, 1}, {-1, 1}, {-1, 0}, {-1, -1}}
+	for d, direction := range directions {
+		ramp := make2DSlice(5, 5)
+		for i := range ramp {
+			for j := range ramp[i] {
+				ramp[i][j] = direction[0] * float32(i) + direction[1] * float32(j)
+			}
+		}
+		best, bestResponse := -1, float32(math.Inf(-1))
+		for n, kernel := range compass {
+			if response := Convolution(ramp, kernel, false)[2][2]; response > bestResponse {
+				best, bestResponse = n, response
+			}
+		}
+		if best != d {
+			fmt.Println("Kirsch direction", d, "picked kernel", best)
+			t.Fail()
+		}
+	}
+
+	// The LoG and DoG kernels should sum to 0, and the separable LoG should match the full one
+	sigma := float32(1.5)
+	size := kernels.GaussianSize(sigma)
+	log := kernels.LaplacianOfGaussian(size, sigma)
+	dog := kernels.DifferenceOfGaussians(size, sigma, 1.6 * sigma)
+	logSum, dogSum := float64(0), float64(0)
+	for i := range log {
+		for j := range log[i] {
+			logSum += float64(log[i][j])
+			dogSum += float64(dog[i][j])
+		}
+	}
+	if math.Abs(logSum) > 1e-5 || math.Abs(dogSum) > 1e-5 || log[size / 2][size / 2] >= 0 {
+		fmt.Println("LoG sum:", logSum, "DoG sum:", dogSum)
+		t.Fail()
+	}
+	gH, gV, d2H, d2V := kernels.SepLaplacianOfGaussian(size, sigma)
+	a := SepConvolution(img, d2H, gV, false)
+	b := SepConvolution(img, gH, d2V, false)
+	separable, _ := AddImage(a, b, false)
+	_, mae, _ := AbsoluteError(Convolution(img, log, false), separable)
+	if mae > 1e-5 {
+		fmt.Println("Separable LoG MAE:", mae)
+		t.Fail()
+	}
+
+	// A sigma of 0 should give the plain Laplacian rather than NaN
+	laplacian := kernels.LaplacianOfGaussian(3, 0)
+	_, _, sae := AbsoluteError(laplacian, [][]float32{{0, 1, 0}, {1, -4, 1}, {0, 1, 0}})
+	if sae != 0 {
+		fmt.Println("LoG with sigma 0:", laplacian)
+		t.Fail()
+	}
+	_, mae, _ = AbsoluteError(LaplacianOfGaussianFilter(img, 0), zeroSumConvolution(img, func(image [][]float32) [][]float32 { return Convolution(image, laplacian, false) }))
+	if math.IsNaN(float64(mae)) || mae > 1e-5 {
+		fmt.Println("LoG filter with sigma 0 MAE:", mae)
+		t.Fail()
+	}
+}
+
+func TestZeroCrossings(t *testing.T) {
+
+	// A bright square on a dark background
+	square := make2DSlice(64, 64)
+	for i := 16; i < 48; i++ {
+		for j := 16; j < 48; j++ {
+			square[i][j] = 1
+		}
+	}
+
+	for name, response := range map[string][][]float32{"log": LaplacianOfGaussianFilter(square, 2), "dog": DifferenceOfGaussiansFilter(square, 2, 3.2)} {
+		edges := ZeroCrossings(response, 0.01)
+
+		// Every row through the middle of the square should cross exactly one edge pixel on each side, close to the boundary
+		for j := 24; j < 40; j++ {
+			left, right := 0, 0
+			for i := 0; i < 32; i++ {
+				if edges[i][j] == 1 {
+					left++
+					if i < 14 || i > 17 {
+						fmt.Println(name, "edge in the wrong place:", i, j)
+						t.Fail()
+					}
+				}
+				if edges[i + 32][j] == 1 {
+					right++
+				}
+			}
+			if left != 1 || right != 1 {
+				fmt.Println(name, "row", j, "has", left, "and", right, "edge pixels")
+				t.Fail()
+			}
+		}
+
+		// The border of the image shouldn't look like an edge
+		if edges[0][32] != 0 || edges[32][0] != 0 {
+			fmt.Println(name, "edge on the border")
+			t.Fail()
+		}
+	}
+
+	img, err := LoadImage("test-images/00-original.jpg")
+	if err != nil {
+		t.Fatal()
+	}
+
+	got := ZeroCrossings(LaplacianOfGaussianFilter(img, 3), 0.005)
+	err = SaveImage("test-images/TestZeroCrossings__00-log.jpg", got)
+	if err != nil {
+		t.Fail()
+	}
+}
+
+func TestGradient(t *testing.T) {
+
+	// A ramp going up by 0.01 per pixel along x and 0.02 per pixel along y
+	ramp := make2DSlice(40, 40)
+	for i := range ramp {
+		for j := range ramp[i] {
+			ramp[i][j] = 0.01 * float32(i) + 0.02 * float32(j)
+		}
+	}
+
+	// Every operator should measure the same gradient, in pixel value units per pixel
+	operators := map[string]GradientOperator{"sobel": SobelOperator, "scharr": ScharrOperator, "prewitt": PrewittOperator, "gaussian": GaussianDerivativeOperator(1.5), "central difference": GaussianDerivativeOperator(0)}
+	for name, operator := range operators {
+		gradients := Gradient(ramp, operator, false)
+		gx, gy := gradients.X[20][20], gradients.Y[20][20]
+		if math.Abs(float64(gx) - 0.01) > 1e-4 || math.Abs(float64(gy) - 0.02) > 1e-4 {
+			fmt.Println(name, "gradient:", gx, gy)
+			t.Fail()
+		}
+		if math.Abs(float64(gradients.Direction[20][20]) - math.Atan2(0.02, 0.01)) > 1e-3 {
+			fmt.Println(name, "direction:", gradients.Direction[20][20])
+			t.Fail()
+		}
+	}
+
+	// Rising and falling edges should point in opposite directions, and the border shouldn't look like an edge
+	clean, _ := noisyStepImage(32, 32, 0)
+	falling := make2DSlice(32, 32)
+	for i := range falling {
+		for j := range falling[i] {
+			falling[i][j] = 1 - clean[i][j]
+		}
+	}
+	rising := Gradient(clean, SobelOperator, false)
+	fell := Gradient(falling, SobelOperator, false)
+	if math.Abs(float64(rising.Direction[16][16])) > 1e-6 || math.Abs(math.Abs(float64(fell.Direction[16][16])) - math.Pi) > 1e-6 {
+		fmt.Println("Directions:", rising.Direction[16][16], fell.Direction[16][16])
+		t.Fail()
+	}
+	if rising.Magnitude[0][10] != 0 || rising.Magnitude[5][0] != 0 {
+		fmt.Println("Border:", rising.Magnitude[0][10], rising.Magnitude[5][0])
+		t.Fail()
+	}
+
+	// Normalising should keep the sign and relative scale of the gradients
+	normalised := Gradient(falling, SobelOperator, true)
+	_, max := MinMax(normalised.Magnitude)
+	if max != 1 || normalised.X[16][16] != -1 || math.Abs(float64(normalised.Direction[16][16]) - 1) > 1e-6 && math.Abs(float64(normalised.Direction[16][16])) > 1e-6 {
+		fmt.Println("Normalised:", max, normalised.X[16][16], normalised.Direction[16][16])
+		t.Fail()
+	}
+
+	img, err := LoadImage("test-images/00-original.jpg")
+	if err != nil {
+		t.Fatal()
+	}
+
+	got := Gradient(img, ScharrOperator, true)
+	err = SaveImage("test-images/TestGradient__00-magnitude.jpg", got.Magnitude)
+	if err != nil {
+		t.Fail()
+	}
+	err = SaveImage("test-images/TestGradient__01-direction.jpg", got.Direction)
+	if err != nil {
+		t.Fail()
+	}
+}
+
+func TestCorners(t *testing.T) {
+
+	// Two bright squares on a dark background, which have 8 corners between them
+	squares := make2DSlice(96, 64)
+	for i := 16; i < 40; i++ {
+		for j := 16; j < 48; j++ {
+			squares[i][j] = 1
+			squares[i + 40][j] = 0.6
+		}
+	}
+	expected := [][2]int{{16, 16}, {39, 16}, {16, 47}, {39, 47}, {56, 16}, {79, 16}, {56, 47}, {79, 47}}
+
+	for name, response := range map[string][][]float32{"harris": HarrisResponse(squares, 1.5, 0.05), "shi-tomasi": ShiTomasiResponse(squares, 1.5)} {
+		corners := DetectCorners(response, 0.05, 5, 0)
+
+		// Every corner should be found, within a couple of pixels, and nothing else
+		if len(corners) != len(expected) {
+			fmt.Println(name, "corners:", corners)
+			t.Fail()
+			continue
+		}
+		for _, want := range expected {
+			found := false
+			for _, corner := range corners {
+				if math.Abs(float64(corner.X - want[0])) <= 2 && math.Abs(float64(corner.Y - want[1])) <= 2 {
+					found = true
+				}
+			}
+			if !found {
+				fmt.Println(name, "missing corner:", want, corners)
+				t.Fail()
+			}
+		}
+
+		// The brighter square has stronger corners, so they should be ranked first
+		for n := range corners {
+			if (n < 4) != (corners[n].X < 48) {
+				fmt.Println(name, "ranking:", corners)
+				t.Fail()
+			}
+		}
+	}
+
+	// The minimum distance and maximum number of corners should be respected
+	corners := DetectCorners(ShiTomasiResponse(squares, 1.5), 0.05, 30, 0)
+	for a := range corners {
+		for b := a + 1; b < len(corners); b++ {
+			if math.Hypot(float64(corners[a].X - corners[b].X), float64(corners[a].Y - corners[b].Y)) < 30 {
+				fmt.Println("Too close:", corners[a], corners[b])
+				t.Fail()
+			}
+		}
+	}
+	if len(DetectCorners(ShiTomasiResponse(squares, 1.5), 0.05, 5, 3)) != 3 {
+		t.Fail()
+	}
+
+	img, err := LoadImage("test-images/00-original.jpg")
+	if err != nil {
+		t.Fatal()
+	}
+
+	response := HarrisResponse(img, 2, 0.05)
+	marked := make2DSlice(Dimensions(img))
+	for _, corner := range DetectCorners(response, 0.01, 20, 500) {
+		for n := -3; n <= 3; n++ {
+			marked[clamp(corner.X + n, len(img))][corner.Y] = 1
+			marked[corner.X][clamp(corner.Y + n, len(img[0]))] = 1
+		}
+	}
+	err = SaveImage("test-images/TestCorners__00-harris.jpg", marked)
+	if err != nil {
+		t.Fail()
+	}
+}
+
+/*
+ * Draws a straight line onto an image
+ */
+func drawLine(image [][]float32, x1 int, y1 int, x2 int, y2 int) {
+	steps := int(math.Max(math.Abs(float64(x2 - x1)), math.Abs(float64(y2 - y1))))
+	for k := 0; k <= steps; k++ {
+		fraction := float64(k) / float64(steps)
+		image[int(math.Round(float64(x1) + fraction * float64(x2 - x1)))][int(math.Round(float64(y1) + fraction * float64(y2 - y1)))] = 1
+	}
+}
+
+func TestHoughLines(t *testing.T) {
+
+	// Three lines, one horizontal, one vertical and one diagonal
+	edges := make2DSlice(100, 80)
+	drawLine(edges, 5, 20, 95, 20)
+	drawLine(edges, 30, 5, 30, 75)
+	drawLine(edges, 50, 70, 90, 30)
+	expected := []Line{{Rho: 20, Theta: math.Pi / 2}, {Rho: 30, Theta: 0}, {Rho: float32(120 / math.Sqrt2), Theta: math.Pi / 4}}
+
+	space, err := HoughLineTransform(edges, 1, math.Pi / 180)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := space.Peaks(30, 5, 0.1, 0)
+	if len(lines) != 3 {
+		fmt.Println("Lines:", lines)
+		t.Fail()
+	}
+	for _, want := range expected {
+		found := false
+		for _, line := range lines {
+			if math.Abs(float64(line.Rho - want.Rho)) <= 1 && math.Abs(float64(line.Theta - want.Theta)) <= 0.02 {
+				found = true
+			}
+		}
+		if !found {
+			fmt.Println("Missing line:", want, lines)
+			t.Fail()
+		}
+	}
+
+	// A theta distance of more than pi should cover every angle, rather than going off the end of the accumulator
+	if wide := space.Peaks(30, 5, 4, 0); len(wide) == 0 || len(wide) > len(lines) {
+		fmt.Println("Wide peaks:", wide)
+		t.Fail()
+	}
+
+	// The strongest line is the horizontal one, which is the longest
+	if len(lines) > 0 && lines[0].Votes != 91 {
+		fmt.Println("Strongest line:", lines[0])
+		t.Fail()
+	}
+
+	// The probabilistic version should find the three segments and their end points
+	segments, err := ProbabilisticHoughLines(edges, 1, math.Pi / 180, 20, 20, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedSegments := []LineSegment{{5, 20, 95, 20}, {30, 5, 30, 75}, {50, 70, 90, 30}}
+	if len(segments) != 3 {
+		fmt.Println("Segments:", segments)
+		t.Fail()
+	}
+	for _, want := range expectedSegments {
+		found := false
+		for _, segment := range segments {
+			forwards := math.Hypot(float64(segment.X1 - want.X1), float64(segment.Y1 - want.Y1)) + math.Hypot(float64(segment.X2 - want.X2), float64(segment.Y2 - want.Y2))
+			backwards := math.Hypot(float64(segment.X1 - want.X2), float64(segment.Y1 - want.Y2)) + math.Hypot(float64(segment.X2 - want.X1), float64(segment.Y2 - want.Y1))
+			if math.Min(forwards, backwards) <= 4 {
+				found = true
+			}
+		}
+		if !found {
+			fmt.Println("Missing segment:", want, segments)
+			t.Fail()
+		}
+	}
+
+	// The resolutions must be positive, and there must be at least one theta bin
+	for _, resolutions := range [][2]float32{{0, math.Pi / 180}, {-1, math.Pi / 180}, {1, 0}, {1, 4}} {
+		if _, err := HoughLineTransform(edges, resolutions[0], resolutions[1]); err == nil {
+			t.Error("Expected an error for resolutions", resolutions)
+		}
+		if _, err := ProbabilisticHoughLines(edges, resolutions[0], resolutions[1], 20, 20, 2); err == nil {
+			t.Error("Expected an error for resolutions", resolutions)
+		}
+	}
+
+	img, err := LoadImage("test-images/00-original.jpg")
+	if err != nil {
+		t.Fatal()
+	}
+
+	img = SubImage(img, 1000, 1000, 1024, 1024)
+	space, err = HoughLineTransform(SingleThreshold(GradientMagnitude(img), 0.3), 1, math.Pi / 360)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = SaveImage("test-images/TestHoughLines__00-accumulator.jpg", Normalise(space.Accumulator))
+	if err != nil {
+		t.Fail()
+	}
+
+	drawn := make2DSlice(1024, 1024)
+	segments, err = ProbabilisticHoughLines(Canny(img, 2, 0.01, 0.03), 1, math.Pi / 180, 50, 50, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, segment := range segments {
+		drawLine(drawn, segment.X1, segment.Y1, segment.X2, segment.Y2)
+	}
+	err = SaveImage("test-images/TestHoughLines__01-probabilistic.jpg", drawn)
+	if err != nil {
+		t.Fail()
+	}
+}
+
+func TestHoughCircles(t *testing.T) {
+
+	// A bright disc and a dark disc on a grey background
+	image := make2DSlice(120, 100)
+	for i := range image {
+		for j := range image[i] {
+			image[i][j] = 0.5
+			if math.Hypot(float64(i - 35), float64(j - 40)) <= 20 {
+				image[i][j] = 1
+			}
+			if math.Hypot(float64(i - 85), float64(j - 60)) <= 12 {
+				image[i][j] = 0
+			}
+		}
+	}
+	expected := []Circle{{X: 35, Y: 40, Radius: 20}, {X: 85, Y: 60, Radius: 12}}
+
+	edges := Canny(image, 1, 0.05, 0.1)
+	circles, err := HoughCircles(edges, Gradient(GaussianBlur(image, 1), SobelOperator, false), 8, 30, 1, 10, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(circles) != 2 {
+		fmt.Println("Circles:", circles)
+		t.Fail()
+	}
+	for _, want := range expected {
+		found := false
+		for _, circle := range circles {
+			if math.Hypot(float64(circle.X - want.X), float64(circle.Y - want.Y)) <= 1.5 && math.Abs(float64(circle.Radius - want.Radius)) <= 1 && circle.Score > 0.5 {
+				found = true
+			}
+		}
+		if !found {
+			fmt.Println("Missing circle:", want, circles)
+			t.Fail()
+		}
+	}
+
+	// Mismatched inputs should be rejected
+	_, err = HoughCircles(edges, Gradient(make2DSlice(10, 10), SobelOperator, false), 8, 30, 1, 10, 15)
+	if err == nil {
+		t.Fail()
+	}
+
+	img, err := LoadImage("test-images/02-original.jpg")
+	if err != nil {
+		t.Fatal()
+	}
+
+	img = SubImage(img, 1000, 1000, 1024, 1024)
+	circles, err = HoughCircles(Canny(img, 2, 0.01, 0.03), Gradient(GaussianBlur(img, 2), SobelOperator, false), 10, 100, 2, 20, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	drawn := make2DSlice(1024, 1024)
+	for _, circle := range circles {
+		for angle := 0.0; angle < 2 * math.Pi; angle += 0.01 {
+			x := int(float64(circle.X) + float64(circle.Radius) * math.Cos(angle))
+			y := int(float64(circle.Y) + float64(circle.Radius) * math.Sin(angle))
+			drawn[clamp(x, 1024)][clamp(y, 1024)] = 1
+		}
+	}
+	err = SaveImage("test-images/TestHoughCircles__00-circles.jpg", drawn)
+	if err != nil {
+		t.Fail()
+	}
+}
+
+func TestStructureTensor(t *testing.T) {
+
+	// Stripes that vary along the direction at angle, so they run along angle + pi/2
+	for _, angle := range []float64{0, math.Pi / 6, math.Pi / 4, -math.Pi / 3} {
+		stripes := make2DSlice(64, 64)
+		for i := range stripes {
+			for j := range stripes[i] {
+				stripes[i][j] = float32(0.5 + 0.5 * math.Sin(0.6 * (float64(i) * math.Cos(angle) + float64(j) * math.Sin(angle))))
+			}
+		}
+
+		analysis := NewStructureTensor(stripes, 1, 3).Analyse()
+		want := angle + math.Pi / 2
+		if want > math.Pi / 2 {
+			want -= math.Pi
+		}
+		got := float64(analysis.Orientation[32][32])
+
+		// Orientations pi apart are the same
+		difference := math.Abs(got - want)
+		difference = math.Min(difference, math.Pi - difference)
+		if difference > 0.02 || analysis.Coherence[32][32] < 0.9 || analysis.Lambda1[32][32] < analysis.Lambda2[32][32] {
+			fmt.Println("Angle:", angle, "orientation:", got, "want:", want, "coherence:", analysis.Coherence[32][32])
+			t.Fail()
+		}
+	}
+
+	// Noise has no dominant orientation
+	_, noise := noisyStepImage(64, 64, 0.2)
+	noise = SubImage(noise, 0, 0, 30, 64)
+	analysis := NewStructureTensor(noise, 1, 4).Analyse()
+	if analysis.Coherence[15][32] > 0.5 || analysis.Anisotropy[15][32] > 0.75 {
+		fmt.Println("Noise coherence:", analysis.Coherence[15][32])
+		t.Fail()
+	}
+
+	img, err := LoadImage("test-images/03-original.jpg")
+	if err != nil {
+		t.Fatal()
+	}
+
+	analysis = NewStructureTensor(img, 1.5, 6).Analyse()
+	err = SaveImage("test-images/TestStructureTensor__00-orientation.jpg", Normalise(analysis.Orientation))
+	if err != nil {
+		t.Fail()
+	}
+	err = SaveImage("test-images/TestStructureTensor__01-coherence.jpg", analysis.Coherence)
+	if err != nil {
+		t.Fail()
+	}
+}
+
+func TestGaborFilterBank(t *testing.T) {
+
+	// Vertical stripes with a wavelength of 8 pixels on the left, and horizontal ones with a wavelength of 16 on the right
+	stripes := make2DSlice(128, 64)
+	for i := range stripes {
+		for j := range stripes[i] {
+			if i < 64 {
+				stripes[i][j] = float32(0.5 + 0.5 * math.Cos(2 * math.Pi * float64(i) / 8))
+			} else {
+				stripes[i][j] = float32(0.5 + 0.5 * math.Cos(2 * math.Pi * float64(j) / 16))
+			}
+		}
+	}
+
+	// The Gabor kernel should give no response to a flat image
+	kernel := kernels.Gabor(kernels.GaborSize(4.48, 0.5), 8, 0, 0, 4.48, 0.5)
+	sum := float64(0)
+	for i := range kernel {
+		for j := range kernel[i] {
+			sum += float64(kernel[i][j])
+		}
+	}
+	if math.Abs(sum) > 1e-4 {
+		fmt.Println("Gabor sum:", sum)
+		t.Fail()
+	}
+
+	// The DC component follows the envelope, so the kernel should still fade away at its corners
+	_, peak := MinMax(kernel)
+	if math.Abs(float64(kernel[0][0])) > 1e-3 * float64(peak) {
+		fmt.Println("Gabor corner:", kernel[0][0], "peak:", peak)
+		t.Fail()
+	}
+
+	// A flat image shouldn't have any texture, even at the border
+	flat, _ := AddScalar(make2DSlice(48, 48), 0.5, false)
+	for n, response := range GaborFilterBank(flat, 4, []float32{8}, 0.5) {
+		if _, max := MinMax(response); max > 1e-4 {
+			fmt.Println("Flat image response", n, ":", max)
+			t.Fail()
+		}
+	}
+
+	// 4 orientations x 2 scales. The strongest filter on the left should be orientation 0 at scale 0, and on the right orientation 2 (pi/2) at scale 1
+	responses := GaborFilterBank(stripes, 4, []float32{8, 16}, 0.5)
+	if len(responses) != 8 {
+		t.Fatal()
+	}
+	strongest := func(i int, j int) int {
+		best := 0
+		for n := range responses {
+			if responses[n][i][j] > responses[best][i][j] {
+				best = n
+			}
+		}
+		return best
+	}
+	if strongest(32, 32) != 0 || strongest(96, 32) != 6 {
+		fmt.Println("Strongest filters:", strongest(32, 32), strongest(96, 32))
+		t.Fail()
+	}
+
+	// The local energy of a quadrature pair shouldn't ripple with the stripes
+	if math.Abs(float64(responses[0][30][32] - responses[0][34][32])) > 0.05 * float64(responses[0][30][32]) {
+		fmt.Println("Energy ripples:", responses[0][30][32], responses[0][34][32])
+		t.Fail()
+	}
+
+	// Feature vectors of the two halves should be different, but the same for two patches of the same texture
+	left := make([][][]float32, len(responses))
+	leftAgain := make([][][]float32, len(responses))
+	right := make([][][]float32, len(responses))
+	for n := range responses {
+		left[n] = SubImage(responses[n], 16, 16, 16, 32)
+		leftAgain[n] = SubImage(responses[n], 32, 16, 16, 32)
+		right[n] = SubImage(responses[n], 80, 16, 32, 32)
+	}
+	distance := func(a []float32, b []float32) float64 {
+		sum := float64(0)
+		for n := range a {
+			sum += float64((a[n] - b[n]) * (a[n] - b[n]))
+		}
+		return math.Sqrt(sum)
+	}
+	leftFeatures, leftAgainFeatures, rightFeatures := TextureFeatures(left), TextureFeatures(leftAgain), TextureFeatures(right)
+	if len(leftFeatures) != 16 || distance(leftFeatures, leftAgainFeatures) * 10 > distance(leftFeatures, rightFeatures) {
+		fmt.Println("Feature distances:", distance(leftFeatures, leftAgainFeatures), distance(leftFeatures, rightFeatures))
+		t.Fail()
+	}
+
+	// The energy maps should be one per response
+	if len(TextureEnergyMaps(responses, 4)) != 8 {
+		t.Fail()
+	}
+
+	img, err := LoadImage("test-images/03-original.jpg")
+	if err != nil {
+		t.Fatal()
+	}
+
+	responses = GaborFilterBank(SubImage(img, 1000, 1000, 256, 256), 4, []float32{8}, 0.5)
+	for n, response := range responses {
+		err = SaveImage(fmt.Sprintf("test-images/TestGaborFilterBank__%02d-response.jpg", n), Normalise(response))
+		if err != nil {
+			t.Fail()
+		}
+	}
+}
+
+func TestDeconvolution(t *testing.T) {
+
+	// Some blobs and a bar on a black background, with a black border so that nothing is blurred off the edge
+	original := make2DSlice(64, 64)
+	for i := range original {
+		for j := range original[i] {
+			if math.Hypot(float64(i - 22), float64(j - 24)) <= 8 {
+				original[i][j] = 0.9
+			}
+			if math.Hypot(float64(i - 42), float64(j - 40)) <= 5 {
+				original[i][j] = 0.5
+			}
+			if i >= 16 && i < 48 && j >= 48 && j < 51 {
+				original[i][j] = 0.7
+			}
+		}
+	}
+
+	// A Gaussian blur, and a horizontal motion blur like a line-scan camera
+	motion := [][]float32{{0.2}, {0.2}, {0.2}, {0.2}, {0.2}}
+	for name, psf := range map[string][][]float32{"gaussian": kernels.Gaussian(9, 1.5), "motion": motion} {
+		blurred := Convolution(original, psf, false)
+		blurredRMSE, _, _ := SquareError(original, blurred)
+
+		wiener, err := WienerDeconvolution(blurred, psf, 1e-5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lucy, err := RichardsonLucy(blurred, psf, 300, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		regularised, err := RichardsonLucy(blurred, psf, 300, 0.002)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Every method should get most of the way back to the original
+		wienerRMSE, _, _ := SquareError(original, wiener)
+		lucyRMSE, _, _ := SquareError(original, lucy)
+		regularisedRMSE, _, _ := SquareError(original, regularised)
+		fmt.Println(name, "RMSE blurred:", blurredRMSE, "wiener:", wienerRMSE, "richardson-lucy:", lucyRMSE, "with tv:", regularisedRMSE)
+		if wienerRMSE > blurredRMSE / 1.5 || lucyRMSE > blurredRMSE / 1.5 || regularisedRMSE > blurredRMSE / 1.5 {
+			t.Fail()
+		}
+	}
+
+	// The FFT should round trip
+	data := [][]complex128{{1, 2, 3, 4}, {5, 6, 7, 8}}
+	fft2D(data, false)
+	fft2D(data, true)
+	if cmplx.Abs(data[1][2] - 7) > 1e-9 {
+		t.Fail()
+	}
+
+	// Bad PSFs should be rejected
+	_, err := RichardsonLucy(original, [][]float32{{0.5, 0.5}}, 10, 0)
+	if err == nil {
+		t.Fail()
+	}
+	_, err = WienerDeconvolution(original, make2DSlice(100, 3), 0.01)
+	if err == nil {
+		t.Fail()
+	}
+
+	img, err := LoadImage("test-images/00-original.jpg")
+	if err != nil {
+		t.Fatal()
+	}
+
+	img = SubImage(img, 1000, 1000, 512, 512)
+	got, err := RichardsonLucy(Convolution(img, motion, false), motion, 30, 0.002)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = SaveImage("test-images/TestDeconvolution__00-richardson-lucy.jpg", Clamp(got, 0, 1))
+	if err != nil {
+		t.Fail()
+	}
+}
+
+func TestCLAHE(t *testing.T) {
+
+	// A faint texture under lighting that gets brighter from left to right
+	img := make2DSlice(128, 64)
+	for i := range img {
+		for j := range img[i] {
+			img[i][j] = 0.1 + 0.7 * float32(i) / 128 + float32(0.03 * math.Sin(float64(i) / 2) * math.Cos(float64(j) / 3))
+		}
+	}
+	_, dimStd := MeanStd(SubImage(img, 8, 8, 24, 48))
+
+	// The texture in the dark part should be stretched, and stretched less when the contrast is limited
+	unlimited, err := CLAHE(img, 4, 2, 0, 256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	limited, err := CLAHE(img, 4, 2, 2, 256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, unlimitedStd := MeanStd(SubImage(unlimited, 8, 8, 24, 48))
+	_, limitedStd := MeanStd(SubImage(limited, 8, 8, 24, 48))
+	fmt.Println("Texture std original:", dimStd, "clip limit 2:", limitedStd, "no clip limit:", unlimitedStd)
+	if limitedStd < 1.25 * dimStd || unlimitedStd <= limitedStd {
+		t.Fail()
+	}
+	min, max := MinMax(unlimited)
+	if min < 0 || max > 1 {
+		t.Fail()
+	}
+
+	// A flat image should stay flat, with no seams between the tiles
+	flat := make2DSlice(64, 64)
+	for i := range flat {
+		for j := range flat[i] {
+			flat[i][j] = 0.5
+		}
+	}
+	got, err := CLAHE(flat, 3, 3, 2, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	min, max = MinMax(got)
+	if max - min > 1e-6 {
+		t.Fail()
+	}
+
+	_, err = CLAHE(flat, 0, 3, 2, 64)
+	if err == nil {
+		t.Fail()
+	}
+
+	img, err = LoadImage("test-images/00-original.jpg")
+	if err != nil {
+		t.Fatal()
+	}
+
+	got, err = CLAHE(img, 8, 8, 3, 256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = SaveImage("test-images/TestCLAHE__00-clahe.jpg", got)
+	if err != nil {
+		t.Fail()
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	img := [][]float32{{0, 0.1, 0.2, 0.3}, {0.4, 0.5, 0.6, 0.7}, {0.8, 0.9, 1, 1.5}}
+
+	// Values outside the range go in the end bins
+	histogram, err := NewHistogram(img, 4, 0, 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []float64{3, 2, 3, 4}
+	for b := range expected {
+		if histogram.Counts[b] != expected[b] {
+			t.Fail()
+		}
+	}
+	if histogram.Total() != 12 || histogram.Cumulative()[2] != 8 || histogram.BinCentre(1) != 0.375 {
+		t.Fail()
+	}
+
+	// The CDF should interpolate within bins, and the inverse CDF should undo it
+	if math.Abs(histogram.CDF(0.125) - 1.5 / 12) > 1e-6 || histogram.CDF(-1) != 0 || histogram.CDF(2) != 1 {
+		t.Fail()
+	}
+	for _, value := range []float32{0.1, 0.3, 0.55, 0.9} {
+		if math.Abs(float64(histogram.InverseCDF(histogram.CDF(value)) - value)) > 1e-5 {
+			t.Fail()
+		}
+	}
+
+	// Only the masked pixels should be counted
+	mask := [][]float32{{1, 1, 0, 0}, {0, 0, 0, 0}, {0, 0, 0, 1}}
+	masked, err := NewHistogram(img, 4, 0, 1, mask)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if masked.Counts[0] != 2 || masked.Counts[3] != 1 || masked.Total() != 3 {
+		t.Fail()
+	}
+
+	_, err = NewHistogram(img, 4, 1, 1, nil)
+	if err == nil {
+		t.Fail()
+	}
+
+	// Equalising a low contrast image should spread its values evenly over 0-1
+	_, noisy := noisyStepImage(64, 64, 0.05)
+	lowContrast, _ := MultiplyScalar(noisy, 0.2, false)
+	lowContrast, _ = AddScalar(lowContrast, 0.3, false)
+	equalised, err := HistogramEqualisation(lowContrast, 256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	equalisedHistogram, _ := NewHistogram(equalised, 4, 0, 1, nil)
+	for _, count := range equalisedHistogram.Counts {
+		if math.Abs(count - 1024) > 64 {
+			t.Fail()
+		}
+	}
+
+	img, err = LoadImage("test-images/00-original.jpg")
+	if err != nil {
+		t.Fatal()
+	}
+
+	got, err := HistogramEqualisation(img, 256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = SaveImage("test-images/TestHistogram__00-equalised.jpg", got)
+	if err != nil {
+		t.Fail()
+	}
+}
+
+func TestMatchHistogram(t *testing.T) {
+
+	// The same scene from a camera with a different tonal response
+	_, reference := noisyTexturedImage(64, 64, 0.05)
+	image := make2DSlice(64, 64)
+	for i := range image {
+		for j := range image[i] {
+			image[i][j] = 0.1 + 0.5 * float32(math.Sqrt(math.Max(0, float64(reference[i][j]))))
+		}
+	}
+	before, _, _ := SquareError(reference, image)
+
+	// Matching should undo the tonal response
+	matched, err := MatchHistogram(image, reference, 256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, _, _ := SquareError(reference, matched)
+	fmt.Println("RMSE before matching:", before, "after matching:", after)
+	if after > before / 10 {
+		t.Fail()
+	}
+
+	// Matching a flat target histogram should spread the values evenly, like equalisation
+	uniform := &Histogram{Counts: []float64{1, 1, 1, 1}, Min: 0, Max: 1}
+	matched, err = MatchHistogramTo(image, uniform, 256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	matchedHistogram, _ := NewHistogram(matched, 4, 0, 1, nil)
+	for _, count := range matchedHistogram.Counts {
+		if math.Abs(count - 1024) > 64 {
+			t.Fail()
+		}
+	}
+
+	_, err = MatchHistogramTo(image, &Histogram{Counts: []float64{0, 0}, Min: 0, Max: 1}, 256)
+	if err == nil {
+		t.Fail()
+	}
+
+	img, err := LoadImage("test-images/00-original.jpg")
+	if err != nil {
+		t.Fatal()
+	}
+
+	got, err := MatchHistogram(img, reference, 256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = SaveImage("test-images/TestMatchHistogram__00-matched.jpg", got)
+	if err != nil {
+		t.Fail()
+	}
+}
+
+func TestToneMapping(t *testing.T) {
+	img := [][]float32{{0, 0.25, 0.5, 0.75, 1}}
+
+	// Check some values of each curve
+	checks := []struct {
+		got [][]float32
+		expected []float32
+	}{
+		{GammaCorrection(img, 2), []float32{0, 0.0625, 0.25, 0.5625, 1}},
+		{LogTransform(img, 0), []float32{0, 0.25, 0.5, 0.75, 1}},
+		{WindowLevel(img, 0.5, 0.5), []float32{0, 0, 0.5, 1, 1}},
+		{WindowLevel(img, 0, 0.5), []float32{0, 0, 1, 1, 1}},
+	}
+	for _, check := range checks {
+		for j := range check.expected {
+			if math.Abs(float64(check.got[0][j] - check.expected[j])) > 1e-6 {
+				t.Fail()
+			}
+		}
+	}
+
+	// The log and exponential curves should be inverses, and both the sigmoid and log curves should keep 0 and 1 fixed
+	roundTrip := ExpTransform(LogTransform(img, 10), float32(math.Log1p(10)))
+	sigmoid := SigmoidContrast(img, 10, 0.5)
+	logged := LogTransform(img, 10)
+	for j := range img[0] {
+		if math.Abs(float64(roundTrip[0][j] - img[0][j])) > 1e-5 {
+			t.Fail()
+		}
+	}
+	if sigmoid[0][0] != 0 || math.Abs(float64(sigmoid[0][4] - 1)) > 1e-6 || sigmoid[0][1] >= 0.25 || math.Abs(float64(sigmoid[0][2] - 0.5)) > 1e-6 {
+		t.Fail()
+	}
+	if logged[0][0] != 0 || math.Abs(float64(logged[0][4] - 1)) > 1e-6 || logged[0][1] <= 0.25 {
+		t.Fail()
+	}
+
+	// A negative gain should undo the sigmoid with the same positive gain
+	unsigmoid := SigmoidContrast(sigmoid, -10, 0.5)
+	for j := range img[0] {
+		if math.Abs(float64(unsigmoid[0][j] - img[0][j])) > 1e-5 {
+			fmt.Println("Sigmoid round trip:", unsigmoid[0])
+			t.Fail()
+		}
+	}
+	if flattened := SigmoidContrast(img, -10, 0.5); flattened[0][1] <= 0.25 || flattened[0][3] >= 0.75 {
+		fmt.Println("Negative gain sigmoid:", flattened[0])
+		t.Fail()
+	}
+
+	// A lookup table should closely match the curve it was sampled from
+	_, noisy := noisyTexturedImage(64, 64, 0.05)
+	lut, err := NewLookupTable(GammaCurve(0.45), 4096, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rmse, _, _ := SquareError(GammaCorrection(Clamp(noisy, 0, 1), 0.45), lut.Apply(noisy))
+	if rmse > 1e-3 {
+		t.Fail()
+	}
+
+	_, err = NewLookupTable(GammaCurve(1), 1, 0, 1)
+	if err == nil {
+		t.Fail()
+	}
+
+	img, err = LoadImage("test-images/00-original.jpg")
+	if err != nil {
+		t.Fatal()
+	}
+
+	err = SaveImage("test-images/TestToneMapping__00-sigmoid.jpg", SigmoidContrast(img, 8, 0.5))
+	if err != nil {
+		t.Fail()
+	}
+}
+
+func TestOtsu(t *testing.T) {
+
+	// Two well separated classes, with the threshold between them
+	_, noisy := noisyStepImage(64, 64, 0.05)
+	threshold, binarised, err := Otsu(noisy, 256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Println("Otsu threshold:", threshold)
+	if threshold < 0.4 || threshold > 0.6 {
+		t.Fail()
+	}
+	for i := range binarised {
+		if binarised[i][10] != 0 && i < 32 || binarised[i][10] != 1 && i >= 32 {
+			t.Fail()
+		}
+	}
+
+	// Three classes, with each pixel labelled by its class
+	random := rand.New(rand.NewSource(3))
+	img := make2DSlice(90, 30)
+	for i := range img {
+		for j := range img[i] {
+			img[i][j] = float32(i / 30) * 0.3 + 0.2 + float32(random.NormFloat64() * 0.03)
+		}
+	}
+	thresholds, labels, err := MultiOtsu(img, 3, 256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Println("Multi-Otsu thresholds:", thresholds)
+	if len(thresholds) != 2 || thresholds[0] < 0.3 || thresholds[0] > 0.4 || thresholds[1] < 0.6 || thresholds[1] > 0.7 {
+		t.Fail()
+	}
+	for i := range labels {
+		if labels[i][5] != float32(i / 30) {
+			t.Fail()
+		}
+	}
+
+	// The two class search should split the histogram in the same place as brute force
+	histogram, _ := NewHistogram(noisy, 64, 0, 1, nil)
+	otsu, _ := OtsuThreshold(histogram)
+	probabilities := histogram.Probabilities()
+	bestVariance, bestSplit := -1.0, 0
+	for split := 1; split < 64; split++ {
+		w0, w1, m0, m1 := 0.0, 0.0, 0.0, 0.0
+		for b, p := range probabilities {
+			if b < split {
+				w0 += p
+				m0 += p * float64(histogram.BinCentre(b))
+			} else {
+				w1 += p
+				m1 += p * float64(histogram.BinCentre(b))
+			}
+		}
+		if w0 == 0 || w1 == 0 {
+			continue
+		}
+		variance := w0 * w1 * (m0 / w0 - m1 / w1) * (m0 / w0 - m1 / w1)
+		if variance > bestVariance {
+			bestVariance, bestSplit = variance, split
+		}
+	}
+	upper := 0.0
+	for b := histogram.Bin(otsu); b < 64; b++ {
+		upper += probabilities[b]
+	}
+	if math.Abs(upper - (1 - histogram.Cumulative()[bestSplit - 1] / histogram.Total())) > 1e-9 {
+		t.Fail()
+	}
+
+	_, err = MultiOtsuThresholds(histogram, 1)
+	if err == nil {
+		t.Fail()
+	}
+
+	img, err = LoadImage("test-images/00-original.jpg")
+	if err != nil {
+		t.Fatal()
+	}
+
+	_, got, err := MultiOtsu(img, 4, 256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = SaveImage("test-images/TestOtsu__00-multi-otsu.jpg", Normalise(got))
+	if err != nil {
+		t.Fail()
+	}
+}
+
+func TestAdaptiveThresholds(t *testing.T) {
+
+	// Dark text on a page that gets brighter from left to right
+	random := rand.New(rand.NewSource(4))
+	page := make2DSlice(128, 128)
+	truth := make2DSlice(128, 128)
+	for i := range page {
+		for j := range page[i] {
+			background := 0.3 + 0.6 * float32(i) / 128
+			if j % 10 < 2 && i % 12 < 8 {
+				page[i][j] = 0.6 * background
+			} else {
+				page[i][j] = background
+				truth[i][j] = 1
+			}
+			page[i][j] += float32(random.NormFloat64() * 0.01)
+		}
+	}
+
+	// Returns the fraction of pixels that match the truth
+	accuracy := func(binarised [][]float32) float64 {
+		correct := 0
+		for i := range binarised {
+			for j := range binarised[i] {
+				if binarised[i][j] == truth[i][j] {
+					correct++
+				}
+			}
+		}
+		return float64(correct) / (128 * 128)
+	}
+
+	// One global threshold can't cope with the lighting, but every local method should
+	_, global, _ := Otsu(page, 256)
+	fmt.Println("Otsu accuracy:", accuracy(global))
+	if accuracy(global) > 0.9 {
+		t.Fail()
+	}
+	methods := map[string][][]float32{
+		"mean-c":   AdaptiveMeanThreshold(page, 15, 0.02),
+		"gaussian": AdaptiveGaussianThreshold(page, 4, 0.02),
+		"niblack":  NiblackThreshold(page, 15, -0.2),
+		"sauvola":  SauvolaThreshold(page, 15, 0.2, 0.5),
+		"wolf":     WolfThreshold(page, 15, 0.5),
+		"bradley":  BradleyThreshold(page, 15, 0.15),
+	}
+	for name, binarised := range methods {
+		fmt.Println(name, "accuracy:", accuracy(binarised))
+		if accuracy(binarised) < 0.97 {
+			t.Fail()
+		}
+	}
+
+	img, err := LoadImage("test-images/00-original.jpg")
+	if err != nil {
+		t.Fatal()
+	}
+
+	err = SaveImage("test-images/TestAdaptiveThresholds__00-sauvola.jpg", SauvolaThreshold(img, 31, 0.2, 0.5))
+	if err != nil {
+		t.Fail()
+	}
+}
+
+func TestThresholdMethods(t *testing.T) {
+
+	// Every method should split two well separated classes between them
+	// The triangle method is meant for unimodal histograms, so it is tested on its own
+	_, noisy := noisyStepImage(64, 64, 0.05)
+	for _, name := range ThresholdMethodNames() {
+		if name == "triangle" {
+			continue
+		}
+		method, err := LookupThresholdMethod(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		threshold, binarised, err := AutomaticThreshold(noisy, method, 256)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Println(name, "threshold:", threshold)
+		if threshold < 0.35 || threshold > 0.65 {
+			t.Fail()
+		}
+		if binarised[10][10] != 0 || binarised[50][10] != 1 {
+			t.Fail()
+		}
+	}
+
+	// A skewed histogram, with a big dark background peak and a long tail of brighter objects
+	random := rand.New(rand.NewSource(5))
+	skewed := make2DSlice(100, 100)
+	for i := range skewed {
+		for j := range skewed[i] {
+			skewed[i][j] = 0.1 + float32(math.Abs(random.NormFloat64()) * 0.03)
+			if i % 20 < 4 && j % 20 < 4 {
+				skewed[i][j] = 0.3 + float32(random.Float64() * 0.6)
+			}
+		}
+	}
+
+	// The triangle method should put the threshold at the foot of the peak
+	threshold, _, err := AutomaticThreshold(skewed, TriangleThreshold, 256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Println("Triangle threshold on skewed histogram:", threshold)
+	if threshold < 0.15 || threshold > 0.3 {
+		t.Fail()
+	}
+
+	// The same should happen when the tail is on the other side
+	inverted, _ := SubtractScalar(skewed, 1, false)
+	inverted, _ = MultiplyScalar(inverted, -1, false)
+	threshold, _, err = AutomaticThreshold(inverted, TriangleThreshold, 256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if threshold < 0.7 || threshold > 0.85 {
+		t.Fail()
+	}
+
+	_, err = LiThreshold(&Histogram{Counts: []float64{0, 0}, Min: 0, Max: 1})
+	if err == nil {
+		t.Fail()
+	}
+
+	_, err = LookupThresholdMethod("unknown")
+	if err == nil {
+		t.Fail()
+	}
+
+	img, err := LoadImage("test-images/00-original.jpg")
+	if err != nil {
+		t.Fatal()
+	}
+
+	method, err := LookupThresholdMethod("minimum-error")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, got, err := AutomaticThreshold(img, method, 256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = SaveImage("test-images/TestThresholdMethods__00-minimum-error.jpg", got)
+	if err != nil {
+		t.Fail()
+	}
+}
+
+func TestGrayscaleMorphology(t *testing.T) {
+	_, img := noisyTexturedImage(50, 40, 0.1)
+
+	// The van Herk/Gil-Werman fast path should match checking every point, for odd and even rectangles
+	for _, size := range [][2]int{{5, 5}, {4, 7}, {1, 6}} {
+		rectangle := make2DSlice(size[0], size[1])
+		for i := range rectangle {
+			for j := range rectangle[i] {
+				rectangle[i][j] = 1
+			}
+		}
+		for _, dilate := range []bool{false, true} {
+			offsets, isRectangle, err := structuringOffsets(rectangle, nil, dilate)
+			if err != nil || !isRectangle {
+				t.Fatal(err)
+			}
+			slow := grayscaleMorphology(img, offsets, dilate)
+			fast, _ := GrayscaleErosion(img, rectangle, nil)
+			if dilate {
+				fast, _ = GrayscaleDilation(img, rectangle, nil)
+			}
+			rmse, _, _ := SquareError(slow, fast)
+			if rmse != 0 {
+				t.Fail()
+			}
+		}
+	}
+
+	// Erosion and dilation should bound the image, opening shouldn't add anything, closing shouldn't take anything away, and both should be idempotent
+	cross := [][]float32{{0, 1, 0}, {1, 1, 1}, {0, 1, 0}}
+	eroded, _ := GrayscaleErosion(img, cross, nil)
+	dilated, _ := GrayscaleDilation(img, cross, nil)
+	opened, _ := GrayscaleOpening(img, cross, nil)
+	closed, _ := GrayscaleClosing(img, cross, nil)
+	openedTwice, _ := GrayscaleOpening(opened, cross, nil)
+	closedTwice, _ := GrayscaleClosing(closed, cross, nil)
+	for i := range img {
+		for j := range img[i] {
+			if eroded[i][j] > img[i][j] || dilated[i][j] < img[i][j] || opened[i][j] > img[i][j] || closed[i][j] < img[i][j] {
+				t.Fail()
+			}
+			if openedTwice[i][j] != opened[i][j] || closedTwice[i][j] != closed[i][j] {
+				t.Fail()
+			}
+		}
+	}
+
+	// An asymmetric element should be reflected for dilation, so that erosion and dilation are dual
+	corner := [][]float32{{1, 1, 0}, {1, 0, 0}, {0, 0, 0}}
+	reflected := [][]float32{{0, 0, 0}, {0, 0, 1}, {0, 1, 1}}
+	negated, _ := MultiplyScalar(img, -1, false)
+	eroded, _ = GrayscaleErosion(img, corner, nil)
+	dilated, _ = GrayscaleDilation(negated, reflected, nil)
+	for i := range img {
+		for j := range img[i] {
+			if eroded[i][j] != -dilated[i][j] {
+				t.Fail()
+			}
+		}
+	}
+
+	// A non-flat element with constant height should just shift a flat erosion
+	heights := [][]float32{{0.1, 0.1, 0.1}, {0.1, 0.1, 0.1}, {0.1, 0.1, 0.1}}
+	flat, _ := GrayscaleErosion(img, cross, nil)
+	nonFlat, _ := GrayscaleErosion(img, cross, heights)
+	for i := range img {
+		for j := range img[i] {
+			if math.Abs(float64(flat[i][j] - 0.1 - nonFlat[i][j])) > 1e-6 {
+				t.Fail()
+			}
+		}
+	}
+
+	_, err := GrayscaleErosion(img, cross, [][]float32{{1}})
+	if err == nil {
+		t.Fail()
+	}
+
+	// Filter sizes must be at least 1
+	for _, size := range [][2]int{{0, 3}, {3, 0}, {-1, 3}} {
+		if _, err := MinFilter(img, size[0], size[1]); err == nil {
+			t.Error("Expected an error for size", size)
+		}
+		if _, err := MaxFilter(img, size[0], size[1]); err == nil {
+			t.Error("Expected an error for size", size)
+		}
+	}
+
+	img, err = LoadImage("test-images/00-original.jpg")
+	if err != nil {
+		t.Fatal()
+	}
+
+	maxFiltered, err := MaxFilter(img, 31, 31)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = SaveImage("test-images/TestGrayscaleMorphology__00-max-filter.jpg", maxFiltered)
+	if err != nil {
+		t.Fail()
+	}
+}
+
+func TestStructuringElements(t *testing.T) {
+
+	// Returns whether two structuring elements are the same
+	equal := func(a [][]float32, b [][]float32) bool {
+		if len(a) != len(b) || len(a[0]) != len(b[0]) {
+			return false
+		}
+		for i := range a {
+			for j := range a[i] {
+				if a[i][j] != b[i][j] {
+					return false
+				}
+			}
+		}
+		return true
+	}
+
+	// Returns the number of points in a structuring element
+	count := func(element [][]float32) int {
+		points := 0
+		for i := range element {
+			for j := range element[i] {
+				if element[i][j] != 0 {
+					points++
+				}
+			}
+		}
+		return points
+	}
+
+	// Check the shapes
+	if count(kernels.Disk(2)) != 13 || count(kernels.Diamond(2)) != 13 || count(kernels.Cross(2)) != 9 || count(kernels.Octagon(2)) != 21 {
+		t.Fail()
+	}
+	if count(kernels.Ellipse(3, 0)) != 7 || len(kernels.Ellipse(3, 1)) != 7 || len(kernels.Ellipse(3, 1)[0]) != 3 {
+		t.Fail()
+	}
+	ring := kernels.Ring(5, 4)
+	if ring[5][5] != 0 || ring[0][5] != 1 || ring[5][10] != 1 {
+		t.Fail()
+	}
+	diagonal := kernels.Line(5, math.Pi / 4)
+	if len(diagonal) != 5 || count(diagonal) != 5 || diagonal[0][0] != 1 || diagonal[4][4] != 1 {
+		t.Fail()
+	}
+
+	// Even length lines should be length pixels long with no gaps, with the extra pixel before the centre
+	if horizontal := kernels.Line(4, 0); !equal(horizontal, [][]float32{{1}, {1}, {1}, {1}, {0}}) {
+		fmt.Println("Horizontal line of 4:", horizontal)
+		t.Fail()
+	}
+	if vertical := kernels.Line(4, math.Pi / 2); !equal(vertical, [][]float32{{1, 1, 1, 1, 0}}) {
+		fmt.Println("Vertical line of 4:", vertical)
+		t.Fail()
+	}
+	if short := kernels.Line(2, 0); !equal(short, [][]float32{{1}, {1}, {0}}) {
+		fmt.Println("Horizontal line of 2:", short)
+		t.Fail()
+	}
+
+	// Rotating and reflecting should behave
+	if !equal(kernels.Rotate(kernels.Line(7, 0), math.Pi / 2), kernels.Line(7, math.Pi / 2)) {
+		t.Fail()
+	}
+	corner := [][]float32{{1, 1, 0}, {1, 0, 0}, {0, 0, 0}}
+	if !equal(kernels.Reflect(corner), [][]float32{{0, 0, 0}, {0, 0, 1}, {0, 1, 1}}) || !equal(kernels.Reflect(kernels.Reflect(corner)), corner) {
+		t.Fail()
+	}
+	if !equal(kernels.Rotate(kernels.Rotate(corner, math.Pi), math.Pi), corner) {
+		t.Fail()
+	}
+
+	// Decompositions should combine back into the whole element
+	if !equal(kernels.Compose(kernels.DecomposeRectangle(5, 3)...), kernels.Rectangle(5, 3)) || !equal(kernels.Compose(kernels.DecomposeDiamond(4)...), kernels.Diamond(4)) {
+		t.Fail()
+	}
+	for radius := 1; radius <= 6; radius++ {
+		if !equal(kernels.Compose(kernels.DecomposeOctagon(radius)...), kernels.Octagon(radius)) {
+			t.Fail()
+		}
+	}
+
+	// And eroding by the pieces in turn should match eroding by the whole element, away from the edges
+	_, img := noisyTexturedImage(40, 40, 0.1)
+	whole, _ := GrayscaleErosion(img, kernels.Octagon(4), nil)
+	pieces := img
+	for _, element := range kernels.DecomposeOctagon(4) {
+		pieces, _ = GrayscaleErosion(pieces, element, nil)
+	}
+	rmse, _, _ := SquareError(SubImage(whole, 4, 4, 32, 32), SubImage(pieces, 4, 4, 32, 32))
+	if rmse != 0 {
+		t.Fail()
+	}
+}
+
+func TestBinaryMask(t *testing.T) {
+
+	// A random binary image that spans a few words, to catch shifts across word boundaries
+	random := rand.New(rand.NewSource(6))
+	img := make2DSlice(150, 40)
+	for i := range img {
+		for j := range img[i] {
+			if random.Float64() < 0.6 {
+				img[i][j] = 1
+			}
+		}
+	}
+	mask := NewBinaryMaskFromImage(img)
+	rmse, _, _ := SquareError(img, mask.ToImage())
+	if rmse != 0 || !mask.Get(149, 39) == (img[149][39] != 0) {
+		t.Fail()
+	}
+
+	// Erosion and dilation should match grayscale morphology on a 0/1 image, including asymmetric elements and wide shifts
+	// The elements all contain their centre, since grayscale morphology leaves a pixel alone when none of the element is inside the image
+	wide := make2DSlice(131, 3)
+	wide[0][0], wide[130][2], wide[65][1] = 1, 1, 1
+	for _, element := range [][][]float32{kernels.Disk(3), {{1, 1, 0}, {1, 1, 0}, {0, 0, 0}}, kernels.Line(9, 0.3), wide} {
+		eroded, err := mask.Erode(element)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dilated, _ := mask.Dilate(element)
+		expectedEroded, _ := GrayscaleErosion(img, element, nil)
+		expectedDilated, _ := GrayscaleDilation(img, element, nil)
+		if !eroded.Equal(NewBinaryMaskFromImage(expectedEroded)) || !dilated.Equal(NewBinaryMaskFromImage(expectedDilated)) {
+			t.Fail()
+		}
+	}
+
+	// Opening and closing should be idempotent
+	opened, _ := mask.Open(kernels.Disk(2))
+	closed, _ := mask.Close(kernels.Disk(2))
+	openedTwice, _ := opened.Open(kernels.Disk(2))
+	closedTwice, _ := closed.Close(kernels.Disk(2))
+	if !opened.Equal(openedTwice) || !closed.Equal(closedTwice) {
+		t.Fail()
+	}
+
+	// Unlike the old BinaryErosion, a square that is one pixel short of the element is removed completely
+	square := make2DSlice(20, 20)
+	for i := 5; i < 11; i++ {
+		for j := 5; j < 11; j++ {
+			square[i][j] = 1
+		}
+	}
+	eroded, _ := NewBinaryMaskFromImage(square).Erode(kernels.Rectangle(7, 7))
+	if eroded.Count() != 0 {
+		t.Fail()
+	}
+	eroded, _ = NewBinaryMaskFromImage(square).Erode(kernels.Rectangle(5, 5))
+	if eroded.Count() != 4 || !eroded.Get(7, 7) || !eroded.Get(8, 8) {
+		t.Fail()
+	}
+
+	// Hit-or-miss should find isolated pixels
+	sparse := NewBinaryMask(70, 10)
+	sparse.Set(3, 3, true)
+	sparse.Set(66, 5, true)
+	sparse.Set(67, 5, true)
+	hits := [][]float32{{0, 0, 0}, {0, 1, 0}, {0, 0, 0}}
+	misses := [][]float32{{1, 1, 1}, {1, 0, 1}, {1, 1, 1}}
+	isolated, err := sparse.HitOrMiss(hits, misses)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if isolated.Count() != 1 || !isolated.Get(3, 3) {
+		t.Fail()
+	}
+
+	// With no misses, hit-or-miss is just an erosion by the hits
+	pair := [][]float32{{0, 0, 0}, {0, 1, 1}, {0, 0, 0}}
+	pairEroded, _ := sparse.Erode(pair)
+	for _, noMisses := range [][][]float32{nil, make2DSlice(3, 3)} {
+		pairs, err := sparse.HitOrMiss(pair, noMisses)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !pairs.Equal(pairEroded) {
+			t.Fail()
+		}
+	}
+
+	// Set operations
+	union, _ := mask.Or(mask.Not())
+	intersection, _ := mask.And(mask.Not())
+	if union.Count() != 150 * 40 || intersection.Count() != 0 {
+		t.Fail()
+	}
+	_, err = mask.And(sparse)
+	if err == nil {
+		t.Fail()
+	}
+}
+
+func TestReconstruction(t *testing.T) {
+
+	// Reconstruction should match repeated geodesic dilation, for both connectivities
+	_, img := noisyTexturedImage(40, 30, 0.1)
+	marker, _ := SubtractScalar(img, 0.2, false)
+	for connectivity, element := range map[int][][]float32{4: kernels.Cross(1), 8: kernels.Rectangle(3, 3)} {
+		expected := marker
+		for {
+			dilated, _ := GrayscaleDilation(expected, element, nil)
+			next := make2DSlice(40, 30)
+			changed := false
+			for i := range next {
+				for j := range next[i] {
+					next[i][j] = float32(math.Min(float64(dilated[i][j]), float64(img[i][j])))
+					if next[i][j] != expected[i][j] {
+						changed = true
+					}
+				}
+			}
+			expected = next
+			if !changed {
+				break
+			}
+		}
+		got, err := ReconstructionByDilation(marker, img, connectivity)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rmse, _, _ := SquareError(expected, got)
+		if rmse != 0 {
+			t.Fail()
+		}
+	}
+
+	// A binarised image with a ring that has a hole, a diagonal pair, an object cut off by the edge and a speck
+	binary := make2DSlice(30, 30)
+	for i := 5; i < 14; i++ {
+		for j := 5; j < 14; j++ {
+			if i == 5 || i == 13 || j == 5 || j == 13 {
+				binary[i][j] = 1
+			}
+		}
+	}
+	for i := 20; i < 30; i++ {
+		for j := 20; j < 25; j++ {
+			binary[i][j] = 1
+		}
+	}
+	binary[20][5], binary[21][6] = 1, 1
+	binary[2][25] = 1
+
+	// Reconstructing from one pixel should recover just the ring
+	seed := make2DSlice(30, 30)
+	seed[5][9] = 1
+	ring, _ := ReconstructionByDilation(seed, binary, 8)
+	if count := NewBinaryMaskFromImage(ring).Count(); count != 32 {
+		t.Fail()
+	}
+
+	// The hole in the ring should be filled, and nothing else
+	filled, err := FillHoles(binary, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filled[9][9] != 1 || filled[2][2] != 0 || NewBinaryMaskFromImage(filled).Count() != NewBinaryMaskFromImage(binary).Count() + 49 {
+		t.Fail()
+	}
+
+	// The object touching the edge should be cleared
+	cleared, _ := ClearBorder(binary, 8)
+	if cleared[25][22] != 0 || cleared[5][5] != 1 || cleared[2][25] != 1 {
+		t.Fail()
+	}
+
+	// The speck should go, and the diagonal pair should only count as one object with 8-connectivity
+	cleaned, _ := RemoveSmallObjects(binary, 2, 4)
+	if cleaned[2][25] != 0 || cleaned[20][5] != 0 || cleaned[5][5] != 1 {
+		t.Fail()
+	}
+	cleaned, _ = RemoveSmallObjects(binary, 2, 8)
+	if cleaned[2][25] != 0 || cleaned[20][5] != 1 {
+		t.Fail()
+	}
+
+	// A small peak should be flattened by h-maxima, and a big one lowered by h
+	peaks := make2DSlice(30, 30)
+	peaks[8][8], peaks[20][20] = 0.3, 0.8
+	peaks[8][9], peaks[20][21] = 0.3, 0.8
+	suppressed, _ := HMaxima(peaks, 0.5, 8)
+	if suppressed[8][8] != 0 || math.Abs(float64(suppressed[20][20] - 0.3)) > 1e-6 || suppressed[0][0] != 0 {
+		t.Fail()
+	}
+	pits, _ := MultiplyScalar(peaks, -1, false)
+	filledPits, _ := HMinima(pits, 0.5, 8)
+	if filledPits[8][8] != 0 || math.Abs(float64(filledPits[20][20] + 0.3)) > 1e-6 {
+		t.Fail()
+	}
+
+	_, err = FillHoles(binary, 6)
+	if err == nil {
+		t.Fail()
+	}
+
+	img, err = LoadImage("test-images/00-original.jpg")
+	if err != nil {
+		t.Fatal()
+	}
+
+	mean, std := MeanStd(img)
+	got, err := FillHoles(DualThreshold(img, mean - 0.5*std, mean + 0.5*std), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = RemoveSmallObjects(got, 50, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = SaveImage("test-images/TestReconstruction__00-cleaned.jpg", got)
+	if err != nil {
+		t.Fail()
+	}
 }
\ No newline at end of file
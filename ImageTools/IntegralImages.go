@@ -0,0 +1,271 @@
+package ImageTools
+
+import (
+	"math"
+	"sync"
+)
+
+/*
+ * An integral image (summed-area table), which gives the sum of any rectangle of pixels in constant time
+ * sums[i][j] holds the sum of every pixel above and to the left of (i, j), so it has an extra row and column of zeros at the start
+ */
+type IntegralImage struct {
+	width, height int
+	sums [][]float64
+}
+
+/*
+ * An integral image that also keeps the sum of the squared pixels, so local variances can be calculated in constant time
+ */
+type SquaredIntegralImage struct {
+	IntegralImage
+	squares [][]float64
+}
+
+/*
+ * Creates an integral image
+ */
+func NewIntegralImage(image [][]float32) *IntegralImage {
+	imageWidth, imageHeight := Dimensions(image)
+	return &IntegralImage{
+		width:  imageWidth,
+		height: imageHeight,
+		sums:   summedAreaTable(image, false),
+	}
+}
+
+/*
+ * Creates a squared integral image
+ */
+func NewSquaredIntegralImage(image [][]float32) *SquaredIntegralImage {
+	imageWidth, imageHeight := Dimensions(image)
+
+	// Build both tables on their own goroutines
+	ch1 := make(chan [][]float64)
+	ch2 := make(chan [][]float64)
+	go func() { ch1 <- summedAreaTable(image, false) }()
+	go func() { ch2 <- summedAreaTable(image, true) }()
+
+	return &SquaredIntegralImage{
+		IntegralImage: IntegralImage{width: imageWidth, height: imageHeight, sums: <- ch1},
+		squares:       <- ch2,
+	}
+}
+
+/*
+ * Builds a summed-area table of an image (or of its squared pixels)
+ */
+func summedAreaTable(image [][]float32, squared bool) [][]float64 {
+
+	imageWidth, imageHeight := Dimensions(image)
+
+	// Create table, with an extra row and column of zeros
+	table := make([][]float64, imageWidth + 1)
+	for i := range table {
+		table[i] = make([]float64, imageHeight + 1)
+	}
+
+	// Create wait group
+	var waitGroup sync.WaitGroup
+	waitGroup.Add(imageHeight)
+
+	// Sum along each row, one goroutine per row
+	for j := 0; j < imageHeight; j++ {
+		go func(j int) {
+			defer waitGroup.Done()
+			accumulator := float64(0)
+			for i := 0; i < imageWidth; i++ {
+				currentPixel := float64(image[i][j])
+				if squared {
+					currentPixel *= currentPixel
+				}
+				accumulator += currentPixel
+				table[i + 1][j + 1] = accumulator
+			}
+		} (j)
+	}
+	waitGroup.Wait()
+
+	// Then sum down each column, one goroutine per column
+	waitGroup.Add(imageWidth)
+	for i := 1; i <= imageWidth; i++ {
+		go func(i int) {
+			defer waitGroup.Done()
+			for j := 1; j <= imageHeight; j++ {
+				table[i][j] += table[i][j - 1]
+			}
+		} (i)
+	}
+	waitGroup.Wait()
+
+	return table
+}
+
+/*
+ * Clips a rectangle to the image, returning its left, top, right and bottom edges (right and bottom are exclusive)
+ */
+func (integral *IntegralImage) clip(topLeftX int, topLeftY int, width int, height int) (int, int, int, int) {
+	left, top := topLeftX, topLeftY
+	right, bottom := topLeftX + width, topLeftY + height
+	if left < 0 {
+		left = 0
+	}
+	if top < 0 {
+		top = 0
+	}
+	if right > integral.width {
+		right = integral.width
+	}
+	if bottom > integral.height {
+		bottom = integral.height
+	}
+	if right < left {
+		right = left
+	}
+	if bottom < top {
+		bottom = top
+	}
+	return left, top, right, bottom
+}
+
+/*
+ * Returns the width and height of the image the integral image was built from
+ */
+func (integral *IntegralImage) Dimensions() (int, int) {
+	return integral.width, integral.height
+}
+
+/*
+ * Returns the number of pixels in a rectangle, once it has been clipped to the image
+ */
+func (integral *IntegralImage) Count(topLeftX int, topLeftY int, width int, height int) int {
+	left, top, right, bottom := integral.clip(topLeftX, topLeftY, width, height)
+	return (right - left) * (bottom - top)
+}
+
+/*
+ * Returns the sum of the pixels in a rectangle
+ * Any part of the rectangle that goes off the edge of the image is ignored (the same as if it was padded with zeros)
+ */
+func (integral *IntegralImage) Sum(topLeftX int, topLeftY int, width int, height int) float64 {
+	left, top, right, bottom := integral.clip(topLeftX, topLeftY, width, height)
+	return integral.sums[right][bottom] - integral.sums[left][bottom] - integral.sums[right][top] + integral.sums[left][top]
+}
+
+/*
+ * Returns the mean of the pixels in a rectangle
+ * Any part of the rectangle that goes off the edge of the image is ignored, and the mean is only over the pixels that are inside it
+ */
+func (integral *IntegralImage) Mean(topLeftX int, topLeftY int, width int, height int) float64 {
+	count := integral.Count(topLeftX, topLeftY, width, height)
+	if count == 0 {
+		return 0
+	}
+	return integral.Sum(topLeftX, topLeftY, width, height) / float64(count)
+}
+
+/*
+ * Returns the sum of the squared pixels in a rectangle
+ */
+func (integral *SquaredIntegralImage) SumSquares(topLeftX int, topLeftY int, width int, height int) float64 {
+	left, top, right, bottom := integral.clip(topLeftX, topLeftY, width, height)
+	return integral.squares[right][bottom] - integral.squares[left][bottom] - integral.squares[right][top] + integral.squares[left][top]
+}
+
+/*
+ * Returns the mean and (population) variance of the pixels in a rectangle
+ * The two metrics are combined because the mean is needed to calculate the variance
+ */
+func (integral *SquaredIntegralImage) MeanVariance(topLeftX int, topLeftY int, width int, height int) (float64, float64) {
+	count := float64(integral.Count(topLeftX, topLeftY, width, height))
+	if count == 0 {
+		return 0, 0
+	}
+	mean := integral.Sum(topLeftX, topLeftY, width, height) / count
+	variance := integral.SumSquares(topLeftX, topLeftY, width, height) / count - mean * mean
+
+	// Rounding error can make the variance of a flat region very slightly negative
+	if variance < 0 {
+		variance = 0
+	}
+	return mean, variance
+}
+
+/*
+ * Returns the mean and (population) standard deviation of the pixels in a rectangle
+ */
+func (integral *SquaredIntegralImage) MeanStd(topLeftX int, topLeftY int, width int, height int) (float64, float64) {
+	mean, variance := integral.MeanVariance(topLeftX, topLeftY, width, height)
+	return mean, math.Sqrt(variance)
+}
+
+/*
+ * Sums the pixels in a size x size window around every pixel, in constant time per pixel
+ * Pixels off the edge of the image are treated as 0, so this gives the same result as a convolution with a kernel of ones
+ */
+func BoxFilter(image [][]float32, size int) [][]float32 {
+	integral := NewIntegralImage(image)
+	return windowFilter(integral.width, integral.height, size, func(x int, y int) float64 {
+		return integral.Sum(x, y, size, size)
+	})
+}
+
+/*
+ * Averages the pixels in a size x size window around every pixel, in constant time per pixel
+ * Near the edges of the image the average is only over the pixels that are inside it, so the border doesn't fade to black
+ */
+func MeanFilter(image [][]float32, size int) [][]float32 {
+	integral := NewIntegralImage(image)
+	return windowFilter(integral.width, integral.height, size, func(x int, y int) float64 {
+		return integral.Mean(x, y, size, size)
+	})
+}
+
+/*
+ * Calculates the (population) standard deviation of the pixels in a size x size window around every pixel, in constant time per pixel
+ */
+func LocalStd(image [][]float32, size int) [][]float32 {
+	integral := NewSquaredIntegralImage(image)
+	return windowFilter(integral.width, integral.height, size, func(x int, y int) float64 {
+		_, std := integral.MeanStd(x, y, size, size)
+		return std
+	})
+}
+
+/*
+ * Evaluates a function of the size x size window around every pixel
+ * The window is centred on the pixel in the same way as Convolution, and the function is given the top left corner of the window
+ */
+func windowFilter(imageWidth int, imageHeight int, size int, window func(x int, y int) float64) [][]float32 {
+
+	half := size / 2
+
+	// Create output image
+	outputImage := make([][]float32, imageWidth)
+	for j := range outputImage {
+		outputImage[j] = make([]float32, imageHeight)
+	}
+
+	// Create wait group
+	var waitGroup sync.WaitGroup
+	waitGroup.Add(imageHeight)
+
+	// Iterate over columns
+	for j := 0; j < imageHeight; j++ {
+
+		// Process each row on its own goroutine
+		go func(j int) {
+			defer waitGroup.Done()
+
+			// Iterate over row
+			for i := 0; i < imageWidth; i++ {
+				outputImage[i][j] = float32(window(i - half, j - half))
+			}
+		} (j)
+	}
+
+	// Wait for all goroutines to finish
+	waitGroup.Wait()
+
+	return outputImage
+}
\ No newline at end of file
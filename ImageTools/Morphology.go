@@ -1,17 +1,13 @@
 package ImageTools
 
-import "ImageTools/kernels"
-
 /*
  * Performs morphological erosion of a binarised image
+ * This is an approximation, which keeps pixels with at least 75% of the square set. Use BinaryMask.Erode for exact erosion
  */
 func BinaryErosion(image [][]float32, size int) [][]float32 {
 
-	// Create structuring element of desired size
-	structuringElement := kernels.BinaryErosionDilationStructuringElement(size)
-
-	// Apply structuring element
-	summed := Convolution(image, structuringElement, false)
+	// Count the set pixels under a square structuring element of the desired size
+	summed := BoxFilter(image, size)
 
 	// Apply threshold
 	return SingleThreshold(summed, float32(size) * float32(size) * 0.75)
@@ -19,14 +15,12 @@ func BinaryErosion(image [][]float32, size int) [][]float32 {
 
 /*
  * Performs morphological dilation of a binarised image
+ * The output is the normalised count of set pixels under the square, rather than 0 or 1. Use BinaryMask.Dilate for exact dilation
  */
 func BinaryDilation(image [][]float32, size int) [][]float32 {
 
-	// Create structuring element of desired size
-	structuringElement := kernels.BinaryErosionDilationStructuringElement(size)
-
-	// Apply structuring element
-	return Convolution(image, structuringElement, true)
+	// Count the set pixels under a square structuring element of the desired size
+	return Normalise(BoxFilter(image, size))
 }
 
 /*
@@ -0,0 +1,258 @@
+package ImageTools
+
+import (
+	"errors"
+	"math"
+)
+
+/*
+ * Performs morphological reconstruction by dilation, growing a marker image inside a mask image (https://doi.org/10.1109/83.217222)
+ * The marker is repeatedly dilated and clipped to the mask until it stops changing, so every region of the mask that the marker touches is recovered and the rest are flattened
+ * Works on grayscale images and on binarised ones, such as the output of SingleThreshold. connectivity is 4 or 8
+ */
+func ReconstructionByDilation(marker [][]float32, mask [][]float32, connectivity int) ([][]float32, error) {
+
+	// Check the parameters
+	neighbours, err := connectivityOffsets(connectivity)
+	if err != nil {
+		return nil, err
+	}
+	imageWidth, imageHeight := Dimensions(mask)
+	markerWidth, markerHeight := Dimensions(marker)
+	if markerWidth != imageWidth || markerHeight != imageHeight {
+		return nil, errors.New("Marker must be the same size as the mask")
+	}
+
+	// Start from the marker, clipped to the mask
+	outputImage := make([][]float32, imageWidth)
+	for i := range outputImage {
+		outputImage[i] = make([]float32, imageHeight)
+		for j := range outputImage[i] {
+			outputImage[i][j] = float32(math.Min(float64(marker[i][j]), float64(mask[i][j])))
+		}
+	}
+
+	// The neighbours that come before a pixel in raster order are the ones with a negative offset
+	var before [][2]int
+	for _, neighbour := range neighbours {
+		if neighbour[1] < 0 || (neighbour[1] == 0 && neighbour[0] < 0) {
+			before = append(before, neighbour)
+		}
+	}
+	inside := func(i int, j int) bool {
+		return i >= 0 && i < imageWidth && j >= 0 && j < imageHeight
+	}
+
+	// Forward raster scan, pulling values down and right from the neighbours already visited
+	for j := 0; j < imageHeight; j++ {
+		for i := 0; i < imageWidth; i++ {
+			value := outputImage[i][j]
+			for _, neighbour := range before {
+				if x, y := i + neighbour[0], j + neighbour[1]; inside(x, y) && outputImage[x][y] > value {
+					value = outputImage[x][y]
+				}
+			}
+			if value > mask[i][j] {
+				value = mask[i][j]
+			}
+			outputImage[i][j] = value
+		}
+	}
+
+	// Backward raster scan the other way, remembering the pixels that could still spread back the way the first scan came
+	var queue [][2]int
+	for j := imageHeight - 1; j >= 0; j-- {
+		for i := imageWidth - 1; i >= 0; i-- {
+			value := outputImage[i][j]
+			for _, neighbour := range before {
+				if x, y := i - neighbour[0], j - neighbour[1]; inside(x, y) && outputImage[x][y] > value {
+					value = outputImage[x][y]
+				}
+			}
+			if value > mask[i][j] {
+				value = mask[i][j]
+			}
+			outputImage[i][j] = value
+			for _, neighbour := range before {
+				x, y := i - neighbour[0], j - neighbour[1]
+				if inside(x, y) && outputImage[x][y] < value && outputImage[x][y] < mask[x][y] {
+					queue = append(queue, [2]int{i, j})
+					break
+				}
+			}
+		}
+	}
+
+	// Spread the remaining values out from the queue until nothing changes
+	for len(queue) > 0 {
+		pixel := queue[0]
+		queue = queue[1:]
+		value := outputImage[pixel[0]][pixel[1]]
+		for _, neighbour := range neighbours {
+			x, y := pixel[0] + neighbour[0], pixel[1] + neighbour[1]
+			if !inside(x, y) || outputImage[x][y] >= value || outputImage[x][y] == mask[x][y] {
+				continue
+			}
+			outputImage[x][y] = float32(math.Min(float64(value), float64(mask[x][y])))
+			queue = append(queue, [2]int{x, y})
+		}
+	}
+
+	return outputImage, nil
+}
+
+/*
+ * Performs morphological reconstruction by erosion, shrinking a marker image down onto a mask image
+ * This is the dual of ReconstructionByDilation, recovering the dark regions of the mask that the marker touches
+ */
+func ReconstructionByErosion(marker [][]float32, mask [][]float32, connectivity int) ([][]float32, error) {
+	negatedMarker, _ := MultiplyScalar(marker, -1, false)
+	negatedMask, _ := MultiplyScalar(mask, -1, false)
+	reconstructed, err := ReconstructionByDilation(negatedMarker, negatedMask, connectivity)
+	if err != nil {
+		return nil, err
+	}
+	return MultiplyScalar(reconstructed, -1, false)
+}
+
+/*
+ * Fills the holes in an image, which are the dark regions that can't be reached from the edge of the image
+ * On a binarised image, this sets every background pixel that is surrounded by foreground. On a grayscale image, it fills dark basins up to the level of their rim
+ * connectivity is the connectivity of the background, 4 or 8
+ */
+func FillHoles(image [][]float32, connectivity int) ([][]float32, error) {
+
+	// The marker is the image on the border, and as bright as the image can be everywhere else
+	imageWidth, imageHeight := Dimensions(image)
+	_, max := MinMax(image)
+	marker := make([][]float32, imageWidth)
+	for i := range marker {
+		marker[i] = make([]float32, imageHeight)
+		for j := range marker[i] {
+			if i == 0 || j == 0 || i == imageWidth - 1 || j == imageHeight - 1 {
+				marker[i][j] = image[i][j]
+			} else {
+				marker[i][j] = max
+			}
+		}
+	}
+
+	return ReconstructionByErosion(marker, image, connectivity)
+}
+
+/*
+ * Removes the bright objects that touch the edge of the image, such as the parts that are cut off in a binarised image
+ * connectivity is the connectivity of the objects, 4 or 8
+ */
+func ClearBorder(image [][]float32, connectivity int) ([][]float32, error) {
+
+	// The marker is the image on the border, and as dark as the image can be everywhere else
+	imageWidth, imageHeight := Dimensions(image)
+	min, _ := MinMax(image)
+	marker := make([][]float32, imageWidth)
+	for i := range marker {
+		marker[i] = make([]float32, imageHeight)
+		for j := range marker[i] {
+			if i == 0 || j == 0 || i == imageWidth - 1 || j == imageHeight - 1 {
+				marker[i][j] = image[i][j]
+			} else {
+				marker[i][j] = min
+			}
+		}
+	}
+
+	// Take away everything that can be reached from the border
+	touching, err := ReconstructionByDilation(marker, image, connectivity)
+	if err != nil {
+		return nil, err
+	}
+	cleared, _ := SubtractImage(image, touching, false)
+	return AddScalar(cleared, min, false)
+}
+
+/*
+ * Removes the objects (connected regions of non-zero pixels) with fewer than minSize pixels from a binarised image
+ * Pixels in the objects that are kept keep their values
+ * connectivity is the connectivity of the objects, 4 or 8
+ */
+func RemoveSmallObjects(image [][]float32, minSize int, connectivity int) ([][]float32, error) {
+
+	neighbours, err := connectivityOffsets(connectivity)
+	if err != nil {
+		return nil, err
+	}
+	imageWidth, imageHeight := Dimensions(image)
+
+	// Create output image
+	outputImage := make([][]float32, imageWidth)
+	for i := range outputImage {
+		outputImage[i] = make([]float32, imageHeight)
+		copy(outputImage[i], image[i])
+	}
+
+	// Flood fill each object, and clear it if it's too small
+	visited := make([][]bool, imageWidth)
+	for i := range visited {
+		visited[i] = make([]bool, imageHeight)
+	}
+	for j := 0; j < imageHeight; j++ {
+		for i := 0; i < imageWidth; i++ {
+			if visited[i][j] || image[i][j] == 0 {
+				continue
+			}
+
+			// Collect the object's pixels
+			visited[i][j] = true
+			object := [][2]int{{i, j}}
+			for n := 0; n < len(object); n++ {
+				for _, neighbour := range neighbours {
+					x, y := object[n][0] + neighbour[0], object[n][1] + neighbour[1]
+					if x < 0 || x >= imageWidth || y < 0 || y >= imageHeight || visited[x][y] || image[x][y] == 0 {
+						continue
+					}
+					visited[x][y] = true
+					object = append(object, [2]int{x, y})
+				}
+			}
+
+			if len(object) < minSize {
+				for _, pixel := range object {
+					outputImage[pixel[0]][pixel[1]] = 0
+				}
+			}
+		}
+	}
+
+	return outputImage, nil
+}
+
+/*
+ * Performs the h-maxima transform, which flattens every regional maximum that is less than h above its surroundings
+ * The maxima that are left are lowered by h. This is useful for picking out significant peaks before looking for local maxima
+ */
+func HMaxima(image [][]float32, h float32, connectivity int) ([][]float32, error) {
+	marker, _ := SubtractScalar(image, h, false)
+	return ReconstructionByDilation(marker, image, connectivity)
+}
+
+/*
+ * Performs the h-minima transform, which fills every regional minimum that is less than h below its surroundings
+ * The minima that are left are raised by h
+ */
+func HMinima(image [][]float32, h float32, connectivity int) ([][]float32, error) {
+	marker, _ := AddScalar(image, h, false)
+	return ReconstructionByErosion(marker, image, connectivity)
+}
+
+/*
+ * Returns the neighbour offsets for 4 or 8-connectivity
+ */
+func connectivityOffsets(connectivity int) ([][2]int, error) {
+	switch connectivity {
+	case 4:
+		return [][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}}, nil
+	case 8:
+		return [][2]int{{-1, -1}, {0, -1}, {1, -1}, {-1, 0}, {1, 0}, {-1, 1}, {0, 1}, {1, 1}}, nil
+	}
+	return nil, errors.New("Connectivity must be 4 or 8")
+}
\ No newline at end of file
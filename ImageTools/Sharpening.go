@@ -0,0 +1,69 @@
+package ImageTools
+
+import (
+	"ImageTools/kernels"
+	"math"
+	"sync"
+)
+
+/*
+ * Sharpens an image with an unsharp mask
+ * The detail layer (the image minus a Gaussian blur with a standard deviation of radius) is scaled by amount and added back on to the image
+ * Detail smaller than threshold is ignored, so that flat, noisy regions aren't sharpened
+ * The output is clamped in the range 0-1 (inclusive) rather than normalised, so it stays comparable with the original
+ */
+func UnsharpMask(image [][]float32, radius float32, amount float32, threshold float32) [][]float32 {
+
+	imageWidth, imageHeight := Dimensions(image)
+
+	// Extract the detail layer
+	blurred := GaussianBlur(image, radius)
+	detail, _ := SubtractImage(image, blurred, false)
+
+	// Scale the detail layer, ignoring anything under the threshold
+	var waitGroup sync.WaitGroup
+	waitGroup.Add(imageHeight)
+
+	// Iterate over columns
+	for j := 0; j < imageHeight; j++ {
+
+		// Process each row on its own goroutine
+		go func(j int) {
+			defer waitGroup.Done()
+
+			// Iterate over row
+			for i := 0; i < imageWidth; i++ {
+				if float32(math.Abs(float64(detail[i][j]))) < threshold {
+					detail[i][j] = 0
+				} else {
+					detail[i][j] *= amount
+				}
+			}
+		} (j)
+	}
+
+	// Wait for all goroutines to finish
+	waitGroup.Wait()
+
+	// Add the detail back on to the original
+	sharpened, _ := AddImage(image, detail, false)
+	return Clamp(sharpened, 0, 1)
+}
+
+/*
+ * Sharpens an image by adding a scaled Laplacian (edge response) back on to it, using kernels.Laplacian
+ * Pixels off the edge of the image are treated as if they were the same as the pixel being sharpened, so the border isn't brightened
+ * The output is clamped in the range 0-1 (inclusive) rather than normalised, so it stays comparable with the original
+ */
+func LaplacianSharpen(image [][]float32, amount float32) [][]float32 {
+
+	// Calculate the edge response, replacing the zero padding with the centre pixel, so a flat region gives no response, even on the border
+	laplacian := zeroSumConvolution(image, func(image [][]float32) [][]float32 {
+		return Convolution(image, kernels.Laplacian, false)
+	})
+
+	// Add the scaled edge response back on to the original
+	laplacian, _ = MultiplyScalar(laplacian, amount, false)
+	sharpened, _ := AddImage(image, laplacian, false)
+	return Clamp(sharpened, 0, 1)
+}
\ No newline at end of file
@@ -0,0 +1,162 @@
+package ImageTools
+
+import (
+	"math"
+	"sync"
+)
+
+/*
+ * The structure tensor of an image, [XX XY; XY YY] at every pixel
+ * Each element is a locally averaged product of the image gradients
+ */
+type StructureTensor struct {
+	XX, XY, YY [][]float32
+}
+
+/*
+ * Per-pixel maps calculated from a structure tensor
+ * Lambda1 and Lambda2 are the eigenvalues (Lambda1 >= Lambda2)
+ * Orientation is the direction the local structure runs along (perpendicular to the dominant gradient), in radians in the range -pi/2 to pi/2
+ * Coherence is ((Lambda1 - Lambda2) / (Lambda1 + Lambda2))^2 and Anisotropy is (Lambda1 - Lambda2) / (Lambda1 + Lambda2). Both are 0 for isotropic or flat regions and 1 for a perfectly oriented pattern
+ */
+type StructureTensorAnalysis struct {
+	Lambda1, Lambda2, Orientation, Coherence, Anisotropy [][]float32
+}
+
+/*
+ * Calculates the structure tensor of an image
+ * The gradients are derivatives of a Gaussian with standard deviation derivativeScale (or Sobel, if derivativeScale is 0)
+ * Their products are averaged by a Gaussian with standard deviation integrationScale, which sets the size of the neighbourhood the orientation is measured over
+ */
+func NewStructureTensor(image [][]float32, derivativeScale float32, integrationScale float32) StructureTensor {
+	operator := SobelOperator
+	if derivativeScale > 0 {
+		operator = GaussianDerivativeOperator(derivativeScale)
+	}
+	xx, xy, yy := structureTensor(image, operator, integrationScale)
+	return StructureTensor{XX: xx, XY: xy, YY: yy}
+}
+
+/*
+ * Calculates the eigenvalues, dominant orientation, coherence and anisotropy of a structure tensor at every pixel
+ * This is a much more robust measure of orientation than PixelOrientation, because it is averaged over a neighbourhood and doesn't care about the sign of the gradient
+ */
+func (tensor StructureTensor) Analyse() StructureTensorAnalysis {
+
+	imageWidth, imageHeight := Dimensions(tensor.XX)
+
+	// Create output images
+	analysis := StructureTensorAnalysis{}
+	for _, output := range []*[][]float32{&analysis.Lambda1, &analysis.Lambda2, &analysis.Orientation, &analysis.Coherence, &analysis.Anisotropy} {
+		*output = make([][]float32, imageWidth)
+		for j := range *output {
+			(*output)[j] = make([]float32, imageHeight)
+		}
+	}
+
+	// Create wait group
+	var waitGroup sync.WaitGroup
+	waitGroup.Add(imageHeight)
+
+	// Iterate over columns
+	for j := 0; j < imageHeight; j++ {
+
+		// Process each row on its own goroutine
+		go func(j int) {
+			defer waitGroup.Done()
+
+			// Iterate over row
+			for i := 0; i < imageWidth; i++ {
+				xx, xy, yy := float64(tensor.XX[i][j]), float64(tensor.XY[i][j]), float64(tensor.YY[i][j])
+				lambda1, lambda2 := eigenvalues(xx, xy, yy)
+				analysis.Lambda1[i][j] = float32(lambda1)
+				analysis.Lambda2[i][j] = float32(lambda2)
+
+				// The dominant gradient is at 0.5 * atan2(2XY, XX - YY), so the structure runs along 0.5 * atan2(-2XY, YY - XX)
+				analysis.Orientation[i][j] = float32(0.5 * math.Atan2(-2 * xy, yy - xx))
+
+				// Avoid dividing by zero in flat regions
+				if lambda1 + lambda2 > 1e-12 {
+					anisotropy := (lambda1 - lambda2) / (lambda1 + lambda2)
+					analysis.Anisotropy[i][j] = float32(anisotropy)
+					analysis.Coherence[i][j] = float32(anisotropy * anisotropy)
+				}
+			}
+		} (j)
+	}
+
+	// Wait for all goroutines to finish
+	waitGroup.Wait()
+
+	return analysis
+}
+
+/*
+ * Calculates the three distinct elements of the structure tensor (Ixx, Ixy, Iyy) at every pixel
+ * The gradients are calculated with the given operator, and their products are averaged by a Gaussian with standard deviation integrationScale
+ */
+func structureTensor(image [][]float32, operator GradientOperator, integrationScale float32) ([][]float32, [][]float32, [][]float32) {
+
+	gradients := Gradient(image, operator, false)
+
+	// Calculate the gradient products
+	xx, _ := MultiplyImage(gradients.X, gradients.X, false)
+	xy, _ := MultiplyImage(gradients.X, gradients.Y, false)
+	yy, _ := MultiplyImage(gradients.Y, gradients.Y, false)
+
+	// Average them, each on its own goroutine
+	ch1 := make(chan [][]float32)
+	ch2 := make(chan [][]float32)
+	ch3 := make(chan [][]float32)
+	go func() { ch1 <- GaussianBlur(xx, integrationScale) }()
+	go func() { ch2 <- GaussianBlur(xy, integrationScale) }()
+	go func() { ch3 <- GaussianBlur(yy, integrationScale) }()
+
+	return <- ch1, <- ch2, <- ch3
+}
+
+/*
+ * Applies a function to the structure tensor ([a b; b c]) at every pixel
+ */
+func tensorMap(xx [][]float32, xy [][]float32, yy [][]float32, function func(a float64, b float64, c float64) float64) [][]float32 {
+
+	imageWidth, imageHeight := Dimensions(xx)
+
+	// Create output image
+	outputImage := make([][]float32, imageWidth)
+	for j := range outputImage {
+		outputImage[j] = make([]float32, imageHeight)
+	}
+
+	// Create wait group
+	var waitGroup sync.WaitGroup
+	waitGroup.Add(imageHeight)
+
+	// Iterate over columns
+	for j := 0; j < imageHeight; j++ {
+
+		// Process each row on its own goroutine
+		go func(j int) {
+			defer waitGroup.Done()
+
+			// Iterate over row
+			for i := 0; i < imageWidth; i++ {
+				outputImage[i][j] = float32(function(float64(xx[i][j]), float64(xy[i][j]), float64(yy[i][j])))
+			}
+		} (j)
+	}
+
+	// Wait for all goroutines to finish
+	waitGroup.Wait()
+
+	return outputImage
+}
+
+/*
+ * Calculates the eigenvalues of the symmetric 2x2 matrix [a b; b c], larger first
+ */
+func eigenvalues(a float64, b float64, c float64) (float64, float64) {
+	halfTrace := 0.5 * (a + c)
+	root := math.Sqrt(0.25 * (a - c) * (a - c) + b * b)
+	return halfTrace + root, halfTrace - root
+}
\ No newline at end of file
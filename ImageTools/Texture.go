@@ -0,0 +1,104 @@
+package ImageTools
+
+import (
+	"ImageTools/kernels"
+	"math"
+	"sync"
+)
+
+/*
+ * Applies a bank of Gabor filters to an image, with orientations evenly spaced between 0 and pi at each of the given wavelengths (scales)
+ * Each filter is a quadrature pair (phases 0 and pi/2), so the response is the local energy sqrt(even^2 + odd^2), which doesn't ripple with the phase of the texture
+ * The envelope of each filter has a 1 octave bandwidth, squashed by aspectRatio across the stripes
+ * The filters sum to 0, so they are applied with zeroSumConvolution and the edge of the image doesn't show up as texture
+ * Returns one magnitude image per filter, indexed [scale * orientations + orientation]
+ */
+func GaborFilterBank(image [][]float32, orientations int, wavelengths []float32, aspectRatio float32) [][][]float32 {
+
+	responses := make([][][]float32, orientations * len(wavelengths))
+
+	// Create wait group
+	var waitGroup sync.WaitGroup
+	waitGroup.Add(len(responses))
+
+	// Process each filter on its own goroutine
+	for scale, wavelength := range wavelengths {
+		for orientation := 0; orientation < orientations; orientation++ {
+			go func(index int, wavelength float32, angle float32) {
+				defer waitGroup.Done()
+
+				// Create the quadrature pair
+				sigma := kernels.GaborSigma(wavelength)
+				size := kernels.GaborSize(sigma, aspectRatio)
+				even := kernels.Gabor(size, wavelength, angle, 0, sigma, aspectRatio)
+				odd := kernels.Gabor(size, wavelength, angle, math.Pi / 2, sigma, aspectRatio)
+
+				// Apply both, and combine them into the local energy
+				a := zeroSumConvolution(image, func(image [][]float32) [][]float32 { return Convolution(image, even, false) })
+				b := zeroSumConvolution(image, func(image [][]float32) [][]float32 { return Convolution(image, odd, false) })
+				a, _ = MultiplyImage(a, a, false)
+				b, _ = MultiplyImage(b, b, false)
+				sum, _ := AddImage(a, b, false)
+				responses[index] = Sqrt(sum, false)
+			} (scale * orientations + orientation, wavelength, float32(math.Pi * float64(orientation) / float64(orientations)))
+		}
+	}
+
+	// Wait for all goroutines to finish
+	waitGroup.Wait()
+
+	return responses
+}
+
+/*
+ * Pools filter bank responses (such as from GaborFilterBank) into a texture feature vector
+ * The vector holds the mean and (population) standard deviation of each response, in the same order as the responses (https://doi.org/10.1109/34.531803)
+ * To describe a region rather than the whole image, pass SubImage of each response
+ */
+func TextureFeatures(responses [][][]float32) []float32 {
+
+	features := make([]float32, 2 * len(responses))
+
+	// Create wait group
+	var waitGroup sync.WaitGroup
+	waitGroup.Add(len(responses))
+
+	// Process each response on its own goroutine
+	for n := range responses {
+		go func(n int) {
+			defer waitGroup.Done()
+			features[2 * n], features[2 * n + 1] = MeanStd(responses[n])
+		} (n)
+	}
+
+	// Wait for all goroutines to finish
+	waitGroup.Wait()
+
+	return features
+}
+
+/*
+ * Pools filter bank responses (such as from GaborFilterBank) into per-pixel texture energy maps
+ * Each response is averaged by a Gaussian with standard deviation sigma, so every pixel gets a feature vector describing the texture around it
+ */
+func TextureEnergyMaps(responses [][][]float32, sigma float32) [][][]float32 {
+
+	energies := make([][][]float32, len(responses))
+
+	// Create wait group
+	var waitGroup sync.WaitGroup
+	waitGroup.Add(len(responses))
+
+	// Process each response on its own goroutine
+	for n := range responses {
+		go func(n int) {
+			defer waitGroup.Done()
+			energies[n] = GaussianBlur(responses[n], sigma)
+		} (n)
+	}
+
+	// Wait for all goroutines to finish
+	waitGroup.Wait()
+
+	return energies
+}
\ No newline at end of file
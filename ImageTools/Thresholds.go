@@ -19,11 +19,7 @@ func SingleThreshold(image [][]float32, threshold float32) [][]float32 {
 
 	// Create wait group
 	var waitGroup sync.WaitGroup
-	if imageWidth > imageHeight {
-		waitGroup.Add(imageWidth)
-	} else {
-		waitGroup.Add(imageHeight)
-	}
+	waitGroup.Add(imageHeight)
 
 	// Iterate over columns
 	for j := 0; j < imageHeight; j++ {
@@ -46,6 +42,9 @@ func SingleThreshold(image [][]float32, threshold float32) [][]float32 {
 		} (j)
 	}
 
+	// Wait for all goroutines to finish
+	waitGroup.Wait()
+
 	return outputImage
 }
 
@@ -71,11 +70,7 @@ func DualThreshold(image [][]float32, thresholdA float32, thresholdB float32) []
 
 	// Create wait group
 	var waitGroup sync.WaitGroup
-	if imageWidth > imageHeight {
-		waitGroup.Add(imageWidth)
-	} else {
-		waitGroup.Add(imageHeight)
-	}
+	waitGroup.Add(imageHeight)
 
 	// Iterate over columns
 	for j := 0; j < imageHeight; j++ {
@@ -98,19 +93,130 @@ func DualThreshold(image [][]float32, thresholdA float32, thresholdB float32) []
 		} (j)
 	}
 
+	// Wait for all goroutines to finish
+	waitGroup.Wait()
+
 	return outputImage
 }
 
+/*
+ * Thresholds an image with 2 thresholds, using hysteresis
+ * Pixels at or above the upper threshold are strong, and are always white
+ * Pixels above the lower threshold but below the upper one are weak, and are only white if they are 8-connected to a strong pixel (possibly through other weak pixels)
+ * The lower comparison is strict, so with a lower threshold of 0 the zero pixels (such as those removed by non-maximum suppression) are never weak
+ * Everything else is black
+ */
 func HysteresisThreshold(image [][]float32, thresholdA float32, thresholdB float32) [][]float32 {
 
+	imageWidth, imageHeight := Dimensions(image)
+
 	// Find which threshold is the upper one and which is the lower one
-	upperThreshold, lowerThreshold := float64(thresholdA), float64(thresholdB)
+	upperThreshold, lowerThreshold := thresholdA, thresholdB
 	if thresholdA < thresholdB {
-		upperThreshold, lowerThreshold = float64(thresholdB), float64(thresholdA)
+		upperThreshold, lowerThreshold = thresholdB, thresholdA
+	}
+
+	// Create output image
+	outputImage := make([][]float32, imageWidth)
+	for j := range outputImage {
+		outputImage[j] = make([]float32, imageHeight)
+	}
+
+	// Find the strong pixels, keeping a list of them for each row
+	seeds := make([][][2]int, imageHeight)
+
+	// Create wait group
+	var waitGroup sync.WaitGroup
+	waitGroup.Add(imageHeight)
+
+	// Iterate over columns
+	for j := 0; j < imageHeight; j++ {
+
+		// Process each row on its own goroutine
+		go func(j int) {
+			defer waitGroup.Done()
+
+			// Iterate over row
+			for i := 0; i < imageWidth; i++ {
+				if image[i][j] >= upperThreshold {
+					outputImage[i][j] = 1
+					seeds[j] = append(seeds[j], [2]int{i, j})
+				}
+			}
+		} (j)
 	}
 
-	//Delete this line
-	upperThreshold += lowerThreshold
+	// Wait for all goroutines to finish
+	waitGroup.Wait()
 
-	return image
+	// Grow out from the strong pixels into any connected weak pixels
+	var stack [][2]int
+	for _, row := range seeds {
+		stack = append(stack, row...)
+	}
+	for len(stack) > 0 {
+		pixel := stack[len(stack) - 1]
+		stack = stack[:len(stack) - 1]
+
+		// Iterate over the 8-neighbourhood
+		for nJ := -1; nJ < 2; nJ++ {
+			for nI := -1; nI < 2; nI++ {
+				i, j := pixel[0] + nI, pixel[1] + nJ
+				if i < 0 || i >= imageWidth || j < 0 || j >= imageHeight {
+					continue
+				}
+				if outputImage[i][j] == 0 && image[i][j] > lowerThreshold {
+					outputImage[i][j] = 1
+					stack = append(stack, [2]int{i, j})
+				}
+			}
+		}
+	}
+
+	return outputImage
+}
+
+/*
+ * Thresholds an image into classes, using any number of thresholds in increasing order
+ * Each pixel is labelled with the number of thresholds it is at or above, so the output has values 0 to len(thresholds)
+ */
+func MultiThreshold(image [][]float32, thresholds []float32) [][]float32 {
+
+	imageWidth, imageHeight := Dimensions(image)
+
+	// Create output image
+	outputImage := make([][]float32, imageWidth)
+	for j := range outputImage {
+		outputImage[j] = make([]float32, imageHeight)
+	}
+
+	// Create wait group
+	var waitGroup sync.WaitGroup
+	waitGroup.Add(imageHeight)
+
+	// Iterate over columns
+	for j := 0; j < imageHeight; j++ {
+
+		// Process each row on its own goroutine
+		go func(j int) {
+			defer waitGroup.Done()
+
+			// Iterate over row
+			for i := 0; i < imageWidth; i++ {
+
+				// Count the thresholds below each pixel
+				currentPixel := image[i][j]
+				label := 0
+				for label < len(thresholds) && currentPixel >= thresholds[label] {
+					label++
+				}
+				outputImage[i][j] = float32(label)
+			}
+		} (j)
+	}
+
+	// Wait for all goroutines to finish
+	waitGroup.Wait()
+
+	return outputImage
 }
\ No newline at end of file
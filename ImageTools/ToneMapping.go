@@ -0,0 +1,190 @@
+package ImageTools
+
+import (
+	"errors"
+	"math"
+)
+
+/*
+ * A tone curve sampled at evenly spaced points between Min and Max, so it can be applied to an image quickly
+ * Values between the samples are linearly interpolated, and values outside the range get the end samples
+ */
+type LookupTable struct {
+	Values []float32
+	Min, Max float32
+}
+
+/*
+ * Builds a lookup table by sampling a curve (such as GammaCurve) at size points from min to max
+ */
+func NewLookupTable(curve func(value float32) float32, size int, min float32, max float32) (*LookupTable, error) {
+	if size < 2 {
+		return nil, errors.New("Lookup table must have at least 2 entries")
+	}
+	if max <= min {
+		return nil, errors.New("Lookup table range must not be empty")
+	}
+	lut := &LookupTable{Values: make([]float32, size), Min: min, Max: max}
+	for n := range lut.Values {
+		lut.Values[n] = curve(min + (max - min) * float32(n) / float32(size - 1))
+	}
+	return lut, nil
+}
+
+/*
+ * Looks up a single value
+ */
+func (lut *LookupTable) Lookup(value float32) float32 {
+	last := len(lut.Values) - 1
+	position := float64(value - lut.Min) / float64(lut.Max - lut.Min) * float64(last)
+	if position <= 0 {
+		return lut.Values[0]
+	}
+	if position >= float64(last) {
+		return lut.Values[last]
+	}
+	n := int(position)
+	fraction := float32(position) - float32(n)
+	return lut.Values[n] + fraction * (lut.Values[n + 1] - lut.Values[n])
+}
+
+/*
+ * Applies the lookup table to every pixel of an image
+ */
+func (lut *LookupTable) Apply(image [][]float32) [][]float32 {
+	return pointOperation(image, lut.Lookup)
+}
+
+/*
+ * Returns the gamma curve value^gamma. Negative values go to 0
+ * Gammas below 1 brighten the dark tones, and gammas above 1 darken them
+ */
+func GammaCurve(gamma float32) func(value float32) float32 {
+	return func(value float32) float32 {
+		if value <= 0 {
+			return 0
+		}
+		return float32(math.Pow(float64(value), float64(gamma)))
+	}
+}
+
+/*
+ * Returns the log curve log(1 + gain * value) / log(1 + gain), which maps 0-1 to 0-1 and brightens the dark tones
+ * Bigger gains give a stronger curve, and a gain of 0 gives a straight line. Values below 0 go to 0
+ */
+func LogCurve(gain float32) func(value float32) float32 {
+	return func(value float32) float32 {
+		if value <= 0 {
+			return 0
+		}
+		if gain <= 0 {
+			return value
+		}
+		return float32(math.Log1p(float64(gain) * float64(value)) / math.Log1p(float64(gain)))
+	}
+}
+
+/*
+ * Returns the exponential curve (exp(gain * value) - 1) / (exp(gain) - 1), which maps 0-1 to 0-1 and darkens the dark tones
+ * It is the inverse of LogCurve with the same gain, once the gain is converted with log(1 + gain)
+ * Bigger gains give a stronger curve, and a gain of 0 gives a straight line
+ */
+func ExpCurve(gain float32) func(value float32) float32 {
+	return func(value float32) float32 {
+		if gain <= 0 {
+			return value
+		}
+		return float32(math.Expm1(float64(gain) * float64(value)) / math.Expm1(float64(gain)))
+	}
+}
+
+/*
+ * Returns a sigmoid contrast curve 1 / (1 + exp(gain * (midpoint - value))), rescaled so that 0 and 1 stay at 0 and 1
+ * Tones either side of midpoint are pushed apart, more strongly for bigger gains, and a gain of 0 gives a straight line
+ * A negative gain gives the inverse of the curve for the positive gain, which pulls tones in towards midpoint. Its values are clipped to 0-1 first, as the inverse isn't defined much beyond them
+ */
+func SigmoidCurve(gain float32, midpoint float32) func(value float32) float32 {
+	steepness := math.Abs(float64(gain))
+	sigmoid := func(value float64) float64 {
+		return 1 / (1 + math.Exp(steepness * (float64(midpoint) - value)))
+	}
+	low, high := sigmoid(0), sigmoid(1)
+	return func(value float32) float32 {
+		if gain == 0 {
+			return value
+		}
+		if gain > 0 {
+			return float32((sigmoid(float64(value)) - low) / (high - low))
+		}
+
+		// Undo the rescaling, then the sigmoid
+		if value <= 0 {
+			return 0
+		}
+		if value >= 1 {
+			return 1
+		}
+		y := low + float64(value) * (high - low)
+		return float32(float64(midpoint) - math.Log(1 / y - 1) / steepness)
+	}
+}
+
+/*
+ * Returns a window/level curve, as used to view medical images
+ * Values from level - window / 2 to level + window / 2 are stretched linearly over 0-1, and values outside the window are clipped to 0 or 1
+ * A window of 0 or less thresholds at level
+ */
+func WindowLevelCurve(window float32, level float32) func(value float32) float32 {
+	return func(value float32) float32 {
+		if window <= 0 {
+			if value >= level {
+				return 1
+			}
+			return 0
+		}
+		scaled := (value - level) / window + 0.5
+		if scaled < 0 {
+			return 0
+		}
+		if scaled > 1 {
+			return 1
+		}
+		return scaled
+	}
+}
+
+/*
+ * Applies a gamma curve to an image (see GammaCurve)
+ * Unlike the trigonometric operators, the output isn't normalised
+ */
+func GammaCorrection(image [][]float32, gamma float32) [][]float32 {
+	return pointOperation(image, GammaCurve(gamma))
+}
+
+/*
+ * Applies a log curve to an image (see LogCurve)
+ */
+func LogTransform(image [][]float32, gain float32) [][]float32 {
+	return pointOperation(image, LogCurve(gain))
+}
+
+/*
+ * Applies an exponential curve to an image (see ExpCurve)
+ */
+func ExpTransform(image [][]float32, gain float32) [][]float32 {
+	return pointOperation(image, ExpCurve(gain))
+}
+
+/*
+ * Applies a sigmoid contrast curve to an image (see SigmoidCurve)
+ */
+func SigmoidContrast(image [][]float32, gain float32, midpoint float32) [][]float32 {
+	return pointOperation(image, SigmoidCurve(gain, midpoint))
+}
+
+/*
+ * Applies a window/level curve to an image (see WindowLevelCurve)
+ */
+func WindowLevel(image [][]float32, window float32, level float32) [][]float32 {
+	return pointOperation(image, WindowLevelCurve(window, level))
+}
\ No newline at end of file
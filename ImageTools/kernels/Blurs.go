@@ -7,6 +7,7 @@ import (
 
 /*
  * Generates a Gaussian kernel of a given size and standard deviation
+ * The peak of the Gaussian is in the centre of the kernel. A sigma of 0 or less gives a kernel that leaves the image unchanged
  */
 func Gaussian(size int, sigma float32) [][]float32 {
 
@@ -30,8 +31,18 @@ func Gaussian(size int, sigma float32) [][]float32 {
 			// Iterate over every pixel in the row
 			for i := 0; i < size; i++ {
 
+				x, y := float64(i - size / 2), float64(j - size / 2)
+
+				// With no spread, all of the weight goes on the centre
+				if sigma <= 0 {
+					if x == 0 && y == 0 {
+						kernel[i][j] = 1
+					}
+					continue
+				}
+
 				a := 1 / (2 * math.Pi * float64(sigma) * float64(sigma))
-				b := math.Pow(math.E, -1 * ((float64(i) * float64(i) + float64(j) * float64(j)) / (2 * float64(sigma) * float64(sigma))))
+				b := math.Pow(math.E, -1 * ((x * x + y * y) / (2 * float64(sigma) * float64(sigma))))
 
 				kernel[i][j] = float32(a * b)
 			}
@@ -44,6 +55,77 @@ func Gaussian(size int, sigma float32) [][]float32 {
 	return NormaliseKernel(kernel)
 }
 
+/*
+ * Generates a separated Gaussian kernel of a given size and standard deviation
+ * The first part is horizontal and the second part is vertical, so they can be passed straight to SepConvolution
+ * A sigma of 0 or less gives kernels that leave the image unchanged
+ */
+func SepGaussian(size int, sigma float32) ([][]float32, [][]float32) {
+
+	// Create empty kernels
+	horizontal := make([][]float32, size)
+	vertical := [][]float32{make([]float32, size)}
+
+	// Calculate 1D Gaussian (the normalisation constant is irrelevant, because the kernel is normalised afterwards)
+	for i := 0; i < size; i++ {
+		x := float64(i - size / 2)
+		value := float32(0)
+		if sigma > 0 {
+			value = float32(math.Exp(-(x * x) / (2 * float64(sigma) * float64(sigma))))
+		} else if x == 0 {
+			value = 1
+		}
+		horizontal[i] = []float32{value}
+		vertical[0][i] = value
+	}
+
+	return NormaliseKernel(horizontal), NormaliseKernel(vertical)
+}
+
+/*
+ * Generates a separated first derivative of Gaussian kernel of a given size and standard deviation
+ * The first part is horizontal and the second part is vertical. Each is scaled so that it gives a response of exactly 1 to a ramp that goes up by 1 per pixel
+ * A sigma of 0 or less gives the central difference [-0.5 0 0.5], so size must be at least 3
+ */
+func SepGaussianDerivative(size int, sigma float32) ([][]float32, [][]float32) {
+
+	// Calculate 1D derivative, G'(x) is proportional to xG(x)
+	derivative := make([]float64, size)
+	ramp := float64(0)
+	for i := 0; i < size; i++ {
+		x := float64(i - size / 2)
+		if sigma > 0 {
+			derivative[i] = x * math.Exp(-(x * x) / (2 * float64(sigma) * float64(sigma)))
+		} else if x == 1 || x == -1 {
+			derivative[i] = x
+		}
+		ramp += x * derivative[i]
+	}
+
+	// Create kernels, scaled so that the response to a ramp is 1
+	horizontal := make([][]float32, size)
+	vertical := [][]float32{make([]float32, size)}
+	for i := 0; i < size; i++ {
+		value := float32(derivative[i] / ramp)
+		horizontal[i] = []float32{value}
+		vertical[0][i] = value
+	}
+
+	return horizontal, vertical
+}
+
+/*
+ * Returns a sensible kernel size for a Gaussian with a given standard deviation
+ * The kernel extends 3 standard deviations either side of the centre, which covers over 99% of the area under the curve
+ * A sigma of 0 or less gives a size of 1
+ */
+func GaussianSize(sigma float32) int {
+	if sigma <= 0 {
+		return 1
+	}
+	return 2 * int(math.Ceil(3 * float64(sigma))) + 1
+}
+
 /*
  * Nornalises a kernel by ensuring that all of its elements sum to 1
  */
@@ -83,4 +165,25 @@ func NormaliseKernel(kernel [][]float32) [][]float32 {
 	waitGroup.Wait()
 
 	return kernel
+}
+
+/*
+ * Transposes a kernel, so that a horizontal kernel becomes vertical and vice versa
+ */
+func Transpose(kernel [][]float32) [][]float32 {
+
+	// Create empty kernel
+	transposed := make([][]float32, len(kernel[0]))
+	for j := range transposed {
+		transposed[j] = make([]float32, len(kernel))
+	}
+
+	// Swap rows and columns
+	for i := range kernel {
+		for j := range kernel[i] {
+			transposed[j][i] = kernel[i][j]
+		}
+	}
+
+	return transposed
 }
\ No newline at end of file
@@ -1,5 +1,7 @@
 package kernels
 
+import "math"
+
 var SobelX = [][]float32 {
 	{-1,  0,  1},
 	{-2,  0,  2},
@@ -37,4 +39,224 @@ var Laplacian = [][]float32 {
 	{-1, -1, 24, -1, -1},
 	{-1, -1, -1, -1, -1},
 	{-1, -1, -1, -1, -1},
+}
+
+var ScharrX = [][]float32 {
+	{ -3, 0,  3},
+	{-10, 0, 10},
+	{ -3, 0,  3},
+}
+
+var ScharrY = [][]float32 {
+	{-3, -10, -3},
+	{ 0,   0,  0},
+	{ 3,  10,  3},
+}
+
+var SepScharrXPt1 = [][]float32 {
+	{3},
+	{10},
+	{3},
+}
+
+var SepScharrXPt2 = [][]float32 {
+	{-1,  0,  1},
+}
+
+var SepScharrYPt1 = [][]float32 {
+	{3, 10, 3},
+}
+
+var SepScharrYPt2 = [][]float32 {
+	{-1},
+	{0},
+	{1},
+}
+
+var PrewittX = [][]float32 {
+	{-1,  0,  1},
+	{-1,  0,  1},
+	{-1,  0,  1},
+}
+
+var PrewittY = [][]float32 {
+	{-1, -1, -1},
+	{ 0,  0,  0},
+	{ 1,  1,  1},
+}
+
+var SepPrewittXPt1 = [][]float32 {
+	{1},
+	{1},
+	{1},
+}
+
+var SepPrewittXPt2 = [][]float32 {
+	{-1,  0,  1},
+}
+
+var SepPrewittYPt1 = [][]float32 {
+	{1, 1, 1},
+}
+
+var SepPrewittYPt2 = [][]float32 {
+	{-1},
+	{0},
+	{1},
+}
+
+var RobertsX = [][]float32 {
+	{1,  0},
+	{0, -1},
+}
+
+var RobertsY = [][]float32 {
+	{ 0, 1},
+	{-1, 0},
+}
+
+/*
+ * Generates the 8 Kirsch compass kernels, starting with north and going clockwise (N, NE, E, SE, S, SW, W, NW)
+ * Each one is the previous one with its outer ring rotated by 45 degrees
+ * The kernels are indexed [x][y] like images, with north towards y = 0, so each one responds most strongly to an edge whose bright side faces its direction
+ */
+func KirschCompass() [][][]float32 {
+
+	// Positions (x, y) of the outer ring, going clockwise from the top left
+	ring := [8][2]int{{0, 0}, {1, 0}, {2, 0}, {2, 1}, {2, 2}, {1, 2}, {0, 2}, {0, 1}}
+	values := [8]float32{5, 5, 5, -3, -3, -3, -3, -3}
+
+	compass := make([][][]float32, 8)
+	for direction := range compass {
+		kernel := [][]float32{make([]float32, 3), make([]float32, 3), make([]float32, 3)}
+		for n, position := range ring {
+			kernel[position[0]][position[1]] = values[(n - direction + 8) % 8]
+		}
+		compass[direction] = kernel
+	}
+
+	return compass
+}
+
+/*
+ * Generates a Laplacian of Gaussian (LoG) kernel of a given size and standard deviation
+ * Built from the separable parts in SepLaplacianOfGaussian, so the two always match
+ * The centre is negative, and the kernel sums to 0. A sigma of 0 or less gives the plain 4-neighbour Laplacian (if size is at least 3)
+ */
+func LaplacianOfGaussian(size int, sigma float32) [][]float32 {
+
+	// Get 1D parts
+	gaussian, secondDerivative := gaussianAndSecondDerivative(size, sigma)
+
+	// LoG(x, y) = G''(x)G(y) + G(x)G''(y)
+	kernel := make([][]float32, size)
+	for i := range kernel {
+		kernel[i] = make([]float32, size)
+		for j := range kernel[i] {
+			kernel[i][j] = float32(secondDerivative[i] * gaussian[j] + gaussian[i] * secondDerivative[j])
+		}
+	}
+
+	return kernel
+}
+
+/*
+ * Generates the separable parts of a Laplacian of Gaussian kernel of a given size and standard deviation
+ * The LoG isn't separable itself, but it is the sum of two separable kernels:
+ * LoG = SepConvolution(G, D2) + SepConvolution(D2, G), where G is the Gaussian and D2 is its second derivative
+ * Returns the horizontal and vertical parts of the Gaussian, followed by the horizontal and vertical parts of the second derivative
+ */
+func SepLaplacianOfGaussian(size int, sigma float32) ([][]float32, [][]float32, [][]float32, [][]float32) {
+
+	// Get 1D parts
+	gaussian, secondDerivative := gaussianAndSecondDerivative(size, sigma)
+
+	// Create empty kernels
+	gaussianHorizontal := make([][]float32, size)
+	gaussianVertical := [][]float32{make([]float32, size)}
+	secondDerivativeHorizontal := make([][]float32, size)
+	secondDerivativeVertical := [][]float32{make([]float32, size)}
+
+	// Copy the 1D parts in
+	for i := 0; i < size; i++ {
+		gaussianHorizontal[i] = []float32{float32(gaussian[i])}
+		gaussianVertical[0][i] = float32(gaussian[i])
+		secondDerivativeHorizontal[i] = []float32{float32(secondDerivative[i])}
+		secondDerivativeVertical[0][i] = float32(secondDerivative[i])
+	}
+
+	return gaussianHorizontal, gaussianVertical, secondDerivativeHorizontal, secondDerivativeVertical
+}
+
+/*
+ * Generates a Difference of Gaussians (DoG) kernel of a given size
+ * The Gaussian with standard deviation sigmaB is subtracted from the Gaussian with standard deviation sigmaA, so the kernel sums to 0
+ * The DoG isn't separable itself, but it is the difference of two separable kernels, so use SepGaussian for each of them to go faster
+ */
+func DifferenceOfGaussians(size int, sigmaA float32, sigmaB float32) [][]float32 {
+
+	// Generate both Gaussians
+	a := Gaussian(size, sigmaA)
+	b := Gaussian(size, sigmaB)
+
+	// Subtract them
+	for i := range a {
+		for j := range a[i] {
+			a[i][j] -= b[i][j]
+		}
+	}
+
+	return a
+}
+
+/*
+ * Generates a 1D Gaussian (summing to 1) and its second derivative (summing to 0) of a given size and standard deviation
+ * A sigma of 0 or less gives no smoothing, so the Gaussian is a single 1 in the centre and the second derivative is the finite difference [1 -2 1]
+ */
+func gaussianAndSecondDerivative(size int, sigma float32) ([]float64, []float64) {
+
+	variance := float64(sigma) * float64(sigma)
+	gaussian := make([]float64, size)
+	secondDerivative := make([]float64, size)
+
+	// With no spread, fall back to the finite difference
+	if sigma <= 0 {
+		centre := size / 2
+		gaussian[centre], secondDerivative[centre] = 1, -2
+		if centre > 0 {
+			secondDerivative[centre - 1] = 1
+		}
+		if centre < size - 1 {
+			secondDerivative[centre + 1] = 1
+		}
+	} else {
+
+		// Calculate the Gaussian, and normalise it
+		sum := float64(0)
+		for i := 0; i < size; i++ {
+			x := float64(i - size / 2)
+			gaussian[i] = math.Exp(-(x * x) / (2 * variance))
+			sum += gaussian[i]
+		}
+		for i := range gaussian {
+			gaussian[i] /= sum
+		}
+
+		// G''(x) = ((x^2 - sigma^2) / sigma^4) G(x)
+		for i := 0; i < size; i++ {
+			x := float64(i - size / 2)
+			secondDerivative[i] = ((x * x - variance) / (variance * variance)) * gaussian[i]
+		}
+	}
+	sum := float64(0)
+	for _, value := range secondDerivative {
+		sum += value
+	}
+
+	// Truncating the kernel stops it summing to exactly 0, so take away a bit of the Gaussian to compensate
+	for i := range secondDerivative {
+		secondDerivative[i] -= sum * gaussian[i]
+	}
+
+	return gaussian, secondDerivative
 }
\ No newline at end of file
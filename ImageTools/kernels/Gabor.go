@@ -0,0 +1,72 @@
+package kernels
+
+import "math"
+
+/*
+ * Generates a Gabor kernel of a given size (https://doi.org/10.1364/JOSAA.2.001160)
+ * A sinusoid with the given wavelength (in pixels) and phase (in radians), under a Gaussian envelope with standard deviation sigma
+ * orientation (in radians) is the direction the sinusoid varies along, measured in image coordinates (x along the first index, y along the second)
+ * aspectRatio squashes the envelope across that direction (1 is circular, smaller values make the kernel longer along the stripes)
+ * The DC component is taken away by subtracting a scaled copy of the envelope, so the kernel gives no response to a flat image but still fades to 0 at its edges
+ */
+func Gabor(size int, wavelength float32, orientation float32, phase float32, sigma float32, aspectRatio float32) [][]float32 {
+
+	cos, sin := math.Cos(float64(orientation)), math.Sin(float64(orientation))
+	variance := float64(sigma) * float64(sigma)
+	gammaSquared := float64(aspectRatio) * float64(aspectRatio)
+
+	// Create empty kernel
+	kernel := make([][]float32, size)
+	for i := range kernel {
+		kernel[i] = make([]float32, size)
+	}
+
+	// Calculate the kernel and the envelope, keeping track of their sums
+	envelopes := make([][]float64, size)
+	sum, envelopeSum := float64(0), float64(0)
+	for i := 0; i < size; i++ {
+		envelopes[i] = make([]float64, size)
+		for j := 0; j < size; j++ {
+
+			// Rotate the coordinates so xPrime runs along the orientation
+			x, y := float64(i - size / 2), float64(j - size / 2)
+			xPrime := x * cos + y * sin
+			yPrime := -x * sin + y * cos
+
+			envelope := math.Exp(-(xPrime * xPrime + gammaSquared * yPrime * yPrime) / (2 * variance))
+			value := envelope * math.Cos(2 * math.Pi * xPrime / float64(wavelength) + float64(phase))
+			kernel[i][j] = float32(value)
+			envelopes[i][j] = envelope
+			sum += value
+			envelopeSum += envelope
+		}
+	}
+
+	// Take away mean(g * cos) / mean(g) of the envelope g, which makes the kernel sum to 0
+	dc := sum / envelopeSum
+	for i := range kernel {
+		for j := range kernel[i] {
+			kernel[i][j] -= float32(dc * envelopes[i][j])
+		}
+	}
+
+	return kernel
+}
+
+/*
+ * Returns the Gaussian standard deviation that gives a Gabor filter a bandwidth of 1 octave at the given wavelength
+ */
+func GaborSigma(wavelength float32) float32 {
+	return 0.56 * wavelength
+}
+
+/*
+ * Returns a sensible kernel size for a Gabor filter
+ * The kernel extends 3 standard deviations either side of the centre, along the longest axis of the envelope
+ */
+func GaborSize(sigma float32, aspectRatio float32) int {
+	if aspectRatio > 0 && aspectRatio < 1 {
+		sigma /= aspectRatio
+	}
+	return GaussianSize(sigma)
+}
\ No newline at end of file
@@ -1,5 +1,7 @@
 package kernels
 
+import "math"
+
 /*
  * Generates a structuring element for use in binary morphological operations
  * (It's just a load of 1s in a matrix of a given size)
@@ -16,4 +18,273 @@ func BinaryErosionDilationStructuringElement(size int) [][]float32 {
 	}
 
 	return structuringElement
+}
+
+/*
+ * Generates a solid rectangular structuring element of a given width and height
+ */
+func Rectangle(width int, height int) [][]float32 {
+	return structuringElement(width, height, func(x int, y int) bool {
+		return true
+	})
+}
+
+/*
+ * Generates a disk shaped structuring element, containing the points within radius of the centre
+ * The element is 2 * radius + 1 pixels wide
+ */
+func Disk(radius int) [][]float32 {
+	return structuringElement(2 * radius + 1, 2 * radius + 1, func(x int, y int) bool {
+		return x * x + y * y <= radius * radius
+	})
+}
+
+/*
+ * Generates a diamond shaped structuring element, containing the points within radius of the centre measured with the city block distance |x| + |y|
+ * It can be broken into smaller elements with DecomposeDiamond
+ */
+func Diamond(radius int) [][]float32 {
+	return structuringElement(2 * radius + 1, 2 * radius + 1, func(x int, y int) bool {
+		return abs(x) + abs(y) <= radius
+	})
+}
+
+/*
+ * Generates a cross (plus sign) shaped structuring element, with arms radius pixels long
+ */
+func Cross(radius int) [][]float32 {
+	return structuringElement(2 * radius + 1, 2 * radius + 1, func(x int, y int) bool {
+		return x == 0 || y == 0
+	})
+}
+
+/*
+ * Generates an octagon shaped structuring element, 2 * radius + 1 pixels across, with corners cut off where |x| + |y| > (3 * radius + 1) / 2
+ * This is close to a regular octagon, and is exactly the shape that DecomposeOctagon breaks into squares and crosses
+ */
+func Octagon(radius int) [][]float32 {
+	return structuringElement(2 * radius + 1, 2 * radius + 1, func(x int, y int) bool {
+		return abs(x) + abs(y) <= (3 * radius + 1) / 2
+	})
+}
+
+/*
+ * Generates an ellipse shaped structuring element, with the given radii along x and y
+ */
+func Ellipse(radiusX int, radiusY int) [][]float32 {
+	return structuringElement(2 * radiusX + 1, 2 * radiusY + 1, func(x int, y int) bool {
+		normalisedX, normalisedY := normaliseOffset(x, radiusX), normaliseOffset(y, radiusY)
+		return normalisedX * normalisedX + normalisedY * normalisedY <= 1
+	})
+}
+
+/*
+ * Generates a ring shaped structuring element, containing the points whose distance from the centre is between innerRadius and outerRadius (inclusive)
+ */
+func Ring(outerRadius int, innerRadius int) [][]float32 {
+	return structuringElement(2 * outerRadius + 1, 2 * outerRadius + 1, func(x int, y int) bool {
+		distanceSquared := x * x + y * y
+		return distanceSquared <= outerRadius * outerRadius && distanceSquared >= innerRadius * innerRadius
+	})
+}
+
+/*
+ * Generates a line shaped structuring element, length pixels long, through the centre at a given angle (in radians)
+ * The angle is measured in image coordinates, from the x axis (the first index) towards the y axis (the second)
+ * The line has one pixel in every step along whichever axis it runs closest to, so it has no gaps
+ * Even length lines have their extra pixel on the negative side of the centre, in the same way as Convolution centres even sized kernels
+ */
+func Line(length int, angle float32) [][]float32 {
+
+	// Step one pixel at a time along the major axis
+	cos, sin := math.Cos(float64(angle)), math.Sin(float64(angle))
+	major := math.Max(math.Abs(cos), math.Abs(sin))
+	stepX, stepY := cos / major, sin / major
+
+	// Find the points, a whole number of steps either side of the centre
+	points := make([][2]int, length)
+	extentX, extentY := 0, 0
+	for n := range points {
+		s := float64(n - length / 2)
+		x, y := int(math.Round(s * stepX)), int(math.Round(s * stepY))
+		points[n] = [2]int{x, y}
+		if abs(x) > extentX {
+			extentX = abs(x)
+		}
+		if abs(y) > extentY {
+			extentY = abs(y)
+		}
+	}
+
+	// Draw them into the smallest element that fits
+	element := structuringElement(2 * extentX + 1, 2 * extentY + 1, func(x int, y int) bool {
+		return false
+	})
+	for _, point := range points {
+		element[point[0] + extentX][point[1] + extentY] = 1
+	}
+
+	return element
+}
+
+/*
+ * Reflects a structuring element through its centre
+ * The reflection is exact for odd sizes. Even sizes have no centre pixel, so the reflected element is shifted by one pixel
+ */
+func Reflect(element [][]float32) [][]float32 {
+	width, height := len(element), len(element[0])
+	reflected := make([][]float32, width)
+	for i := range reflected {
+		reflected[i] = make([]float32, height)
+	}
+	for i := range element {
+		for j := range element[i] {
+			reflected[width - 1 - i][height - 1 - j] = element[i][j]
+		}
+	}
+	return reflected
+}
+
+/*
+ * Rotates a structuring element about its centre by an angle (in radians), measured in the same way as Line
+ * The output is made big enough to hold the rotated element, and each of its pixels takes the value of the nearest pixel it came from, so rotations by multiples of 90 degrees are exact
+ */
+func Rotate(element [][]float32, angle float32) [][]float32 {
+
+	width, height := len(element), len(element[0])
+	cos, sin := math.Cos(float64(angle)), math.Sin(float64(angle))
+
+	// Find how big the rotated element is
+	extentX, extentY := 0, 0
+	for i := range element {
+		for j := range element[i] {
+			if element[i][j] == 0 {
+				continue
+			}
+			x, y := float64(i - width / 2), float64(j - height / 2)
+			rotatedX, rotatedY := int(math.Round(x * cos - y * sin)), int(math.Round(x * sin + y * cos))
+			if abs(rotatedX) > extentX {
+				extentX = abs(rotatedX)
+			}
+			if abs(rotatedY) > extentY {
+				extentY = abs(rotatedY)
+			}
+		}
+	}
+
+	// Rotate each output pixel back into the original element and take the nearest pixel
+	return structuringElement(2 * extentX + 1, 2 * extentY + 1, func(x int, y int) bool {
+		sourceI := int(math.Round(float64(x) * cos + float64(y) * sin)) + width / 2
+		sourceJ := int(math.Round(-float64(x) * sin + float64(y) * cos)) + height / 2
+		return sourceI >= 0 && sourceI < width && sourceJ >= 0 && sourceJ < height && element[sourceI][sourceJ] != 0
+	})
+}
+
+/*
+ * Combines structuring elements into one (their Minkowski sum)
+ * Eroding or dilating by each of the elements in turn is the same as eroding or dilating once by the combined element (away from the edges of the image, where the pixels that are ignored differ), which is how the Decompose functions speed things up
+ */
+func Compose(elements ...[][]float32) [][]float32 {
+	composed := [][]float32{{1}}
+	for _, element := range elements {
+		width, height := len(element), len(element[0])
+		composedWidth, composedHeight := len(composed), len(composed[0])
+
+		// Every pair of points adds up to a point of the combined element
+		sum := make([][]float32, composedWidth + width - 1)
+		for i := range sum {
+			sum[i] = make([]float32, composedHeight + height - 1)
+		}
+		for cI := range composed {
+			for cJ := range composed[cI] {
+				if composed[cI][cJ] == 0 {
+					continue
+				}
+				for i := range element {
+					for j := range element[i] {
+						if element[i][j] != 0 {
+							sum[cI + i][cJ + j] = 1
+						}
+					}
+				}
+			}
+		}
+		composed = sum
+	}
+	return composed
+}
+
+/*
+ * Breaks a rectangular structuring element into a horizontal line and a vertical line
+ * Eroding or dilating by each in turn gives the same result as the whole rectangle, but only checks width + height points per pixel instead of width * height
+ */
+func DecomposeRectangle(width int, height int) [][][]float32 {
+	return [][][]float32{Rectangle(width, 1), Rectangle(1, height)}
+}
+
+/*
+ * Breaks a Diamond structuring element into radius crosses of radius 1
+ */
+func DecomposeDiamond(radius int) [][][]float32 {
+	elements := make([][][]float32, radius)
+	for n := range elements {
+		elements[n] = Cross(1)
+	}
+	return elements
+}
+
+/*
+ * Breaks an Octagon structuring element into 3x3 squares and crosses of radius 1, alternating between them
+ * There are (radius + 1) / 2 squares and radius / 2 crosses
+ */
+func DecomposeOctagon(radius int) [][][]float32 {
+	elements := make([][][]float32, radius)
+	for n := range elements {
+		if n % 2 == 0 {
+			elements[n] = Rectangle(3, 3)
+		} else {
+			elements[n] = Cross(1)
+		}
+	}
+	return elements
+}
+
+/*
+ * Generates a structuring element of a given size, with the points where inside returns true set to 1
+ * inside is given the offset from the centre of the element
+ */
+func structuringElement(width int, height int, inside func(x int, y int) bool) [][]float32 {
+	element := make([][]float32, width)
+	for i := range element {
+		element[i] = make([]float32, height)
+		for j := range element[i] {
+			if inside(i - width / 2, j - height / 2) {
+				element[i][j] = 1
+			}
+		}
+	}
+	return element
+}
+
+/*
+ * Divides an offset by a radius, treating a radius of 0 as a line that only contains an offset of 0
+ */
+func normaliseOffset(offset int, radius int) float64 {
+	if radius == 0 {
+		if offset == 0 {
+			return 0
+		}
+		return 2
+	}
+	return float64(offset) / float64(radius)
+}
+
+/*
+ * Returns the absolute value of an int
+ */
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
 }
\ No newline at end of file